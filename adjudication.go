@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MaxSolveEmpty is the largest number of empty cells a position
+// can have for it to be solved. Positions with more empty cells
+// can take too long to solve between moves
+const MaxSolveEmpty = 14
+
+// Adjudication ends games between engines early, either once the
+// engines agree on the result or once the position can be solved.
+// Each way is disabled while its number of moves or cells is zero
+type Adjudication struct {
+	// ResignMoves and ResignScore end a game as a win once both
+	// engines have scored the same player ahead by at least
+	// ResignScore for ResignMoves consecutive moves each
+	ResignMoves int `json:"resignmoves"`
+	ResignScore int `json:"resignscore"`
+	// DrawMoves and DrawScore end a game as a draw once both
+	// engines have given scores no further than DrawScore from
+	// zero for DrawMoves consecutive moves each
+	DrawMoves int `json:"drawmoves"`
+	DrawScore int `json:"drawscore"`
+	// SolveEmpty ends a game with its result under perfect play
+	// once there are at most SolveEmpty empty cells
+	SolveEmpty int `json:"solveempty"`
+}
+
+// Validate checks that the adjudication's settings are usable
+func (a Adjudication) Validate() error {
+	if a.ResignMoves < 0 || a.DrawMoves < 0 {
+		return errors.New("number of moves can't be negative")
+	}
+	if a.ResignScore < 0 || a.DrawScore < 0 {
+		return errors.New("score can't be negative")
+	}
+	if a.ResignMoves > 0 && a.ResignScore == 0 {
+		return errors.New("resign score must be positive")
+	}
+	if a.SolveEmpty < 0 || a.SolveEmpty > MaxSolveEmpty {
+		return errors.Errorf("number of empty cells to solve must be between 0 and %d", MaxSolveEmpty)
+	}
+	return nil
+}
+
+// ParseAdjudicationRule parses a number of moves and a score
+// written as moves:score, e.g. 4:600 for ResignMoves and ResignScore
+func ParseAdjudicationRule(s string) (int, int, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("rule must be written as moves:score")
+	}
+	moves, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid number of moves")
+	}
+	score, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "invalid score")
+	}
+	return moves, score, nil
+}
+
+// adjudicate decides whether a game should be ended early from its
+// state and the searches for each of its moves. The winner is returned
+// along with why the game was ended, or false if it should go on
+func (a Adjudication) adjudicate(s State, searches []MoveSearch) (int, string, bool) {
+	if s.Winner != Empty {
+		return Empty, "", false
+	}
+	// Solving the position is the most accurate
+	if empty := 42 - s.Turn; a.SolveEmpty > 0 && empty <= a.SolveEmpty {
+		return Solve(s), fmt.Sprintf("solved with %d empty cells", empty), true
+	}
+	// agree checks that each of the latest scores holds to a predicate
+	agree := func(moves int, predicate func(int) bool) bool {
+		n := 2 * moves
+		if moves == 0 || len(searches) < n {
+			return false
+		}
+		for _, search := range searches[len(searches)-n:] {
+			if !search.HasScore || !predicate(search.Score) {
+				return false
+			}
+		}
+		return true
+	}
+	if agree(a.ResignMoves, func(v int) bool { return v >= a.ResignScore }) {
+		return Player1, fmt.Sprintf("engines agreed Player1 was winning for %d moves", a.ResignMoves), true
+	}
+	if agree(a.ResignMoves, func(v int) bool { return v <= -a.ResignScore }) {
+		return Player2, fmt.Sprintf("engines agreed Player2 was winning for %d moves", a.ResignMoves), true
+	}
+	if agree(a.DrawMoves, func(v int) bool { return -a.DrawScore <= v && v <= a.DrawScore }) {
+		return Tie, fmt.Sprintf("engines agreed the game was drawn for %d moves", a.DrawMoves), true
+	}
+	return Empty, "", false
+}
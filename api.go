@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// APIPrefix is the path which all REST API routes are under
+	APIPrefix = "/api/"
+	// apiMaxBodySize is the largest request body the REST API reads
+	apiMaxBodySize = 1 << 20
+)
+
+// apiRoute is a route of the REST API. Each route makes one of
+// the requests in messages.go, the same as the WebSocket protocol
+type apiRoute struct {
+	method string
+	// path is RELATIVE to APIPrefix. Segments in braces
+	// are parameters, e.g. engines/{id}
+	path string
+	// status is the status of a successful response
+	// which has a body
+	status int
+	// request makes the request from the parameters
+	// in the path and the request body
+	request func(params map[string]string, body []byte) (interface{}, error)
+}
+
+// apiRoutes returns every route of the REST API
+// The routes are described by develop/openapi.json
+func apiRoutes() []apiRoute {
+	// static makes a request which needs no parameters
+	static := func(request interface{}) func(map[string]string, []byte) (interface{}, error) {
+		return func(map[string]string, []byte) (interface{}, error) {
+			return request, nil
+		}
+	}
+	return []apiRoute{
+		{"GET", "engines", http.StatusOK, static(EnginesRequest{})},
+		{"POST", "engines", http.StatusCreated, func(p map[string]string, body []byte) (interface{}, error) {
+			r := LoadEngineRequest{}
+			return r, apiBody(body, &r)
+		}},
+		{"GET", "engines/paths", http.StatusOK, static(EnginePathsRequest{})},
+		{"GET", "engines/{id}", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			id, err := apiInt(p, "id")
+			return EngineRequest{ID: id}, err
+		}},
+		{"DELETE", "engines/{id}", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			id, err := apiInt(p, "id")
+			return UnloadEngineRequest{ID: id}, err
+		}},
+		{"PUT", "engines/{id}/restart", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SetRestartRequest{}
+			if err := apiBody(body, &r); err != nil {
+				return nil, err
+			}
+			id, err := apiInt(p, "id")
+			r.ID = id
+			return r, err
+		}},
+		{"PUT", "engines/{id}/ponder", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SetPonderRequest{}
+			if err := apiBody(body, &r); err != nil {
+				return nil, err
+			}
+			id, err := apiInt(p, "id")
+			r.ID = id
+			return r, err
+		}},
+		{"POST", "engines/{id}/profile", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SaveProfileRequest{}
+			if err := apiBody(body, &r); err != nil {
+				return nil, err
+			}
+			id, err := apiInt(p, "id")
+			r.ID = id
+			return r, err
+		}},
+		{"GET", "engines/{id}/options", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			id, err := apiInt(p, "id")
+			return OptionsRequest{EngineID: id}, err
+		}},
+		{"PUT", "engines/{id}/options/{name}", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SetOptionRequest{}
+			if err := apiBody(body, &r); err != nil {
+				return nil, err
+			}
+			id, err := apiInt(p, "id")
+			r.EngineID = id
+			r.Name = p["name"]
+			return r, err
+		}},
+		{"GET", "game", http.StatusOK, static(GameRequest{})},
+		{"POST", "game/new", http.StatusOK, static(NewGameRequest{})},
+		{"PUT", "game/players", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			// Players which aren't given are left as they are
+			r := SetPlayersRequest{Player1: -1, Player2: -1}
+			return r, apiBody(body, &r)
+		}},
+		{"PUT", "game/position", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SetPositionRequest{}
+			return r, apiBody(body, &r)
+		}},
+		{"PUT", "game/limits", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			r := SetLimitsRequest{}
+			return r, apiBody(body, &r)
+		}},
+		{"POST", "game/play", http.StatusOK, static(PlayRequest{})},
+		{"POST", "game/pause", http.StatusOK, static(PauseRequest{})},
+		{"GET", "matches", http.StatusOK, static(MatchesRequest{})},
+		{"POST", "matches", http.StatusCreated, func(p map[string]string, body []byte) (interface{}, error) {
+			r := StartMatchRequest{}
+			return r, apiBody(body, &r)
+		}},
+		{"GET", "matches/{id}", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			id, err := apiInt(p, "id")
+			return MatchRequest{ID: id}, err
+		}},
+		{"POST", "matches/{id}/stop", http.StatusOK, func(p map[string]string, body []byte) (interface{}, error) {
+			id, err := apiInt(p, "id")
+			return StopMatchRequest{ID: id}, err
+		}},
+		{"GET", "results", http.StatusOK, static(ResultsRequest{})},
+	}
+}
+
+// match checks whether the segments of a path match the route
+// The values of the route's parameters are returned
+func (r apiRoute) match(segments []string) (map[string]string, bool) {
+	pattern := strings.Split(r.path, "/")
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			params[p[1:len(p)-1]] = segments[i]
+		} else if p != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// apiBody decodes a JSON request body into v
+// An empty body leaves v as it is
+func apiBody(body []byte, v interface{}) error {
+	if len(body) == 0 {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(body, v), "couldn't parse request body")
+}
+
+// apiInt gets an integer parameter from a path
+func apiInt(params map[string]string, name string) (int, error) {
+	result, err := strconv.Atoi(params[name])
+	if err != nil {
+		return 0, errors.Errorf("invalid %s %q", name, params[name])
+	}
+	return result, nil
+}
+
+// apiHandler handles requests to the REST API
+func (s *Server) apiHandler(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix), "/"), "/")
+	// Find the route of the request
+	pathFound := false
+	for _, route := range apiRoutes() {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if route.method != r.Method {
+			continue
+		}
+		// Make the request
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, apiMaxBodySize))
+		if err != nil {
+			apiError(w, http.StatusBadRequest, errors.Wrap(err, "couldn't read request body"))
+			return
+		}
+		request, err := route.request(params, body)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err)
+			return
+		}
+		// Execute the request
+		responses, err := s.apiRequest(request)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		apiWrite(w, route.status, responses[0])
+		return
+	}
+	if pathFound {
+		apiError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+	} else {
+		apiError(w, http.StatusNotFound, errors.Errorf("no route %s", r.URL.Path))
+	}
+}
+
+// apiRequest executes a request in the same way as a request
+// from a WebSocket. Every response is returned apart from the
+// final OkMessage or ErrorMessage, which becomes the error
+func (s *Server) apiRequest(request interface{}) ([]Message, error) {
+	if s.clientEvents == nil {
+		return nil, errors.New("server isn't handling requests")
+	}
+	responses := make(chan Message, EventBufferSize)
+	s.clientEvents <- ClientEvent{
+		ClientID:  -1,
+		Request:   request,
+		responses: responses,
+	}
+	var result []Message
+	for response := range responses {
+		switch v := response.(type) {
+		case OkMessage:
+			return result, nil
+		case ErrorMessage:
+			return result, errors.New(v.Message)
+		}
+		result = append(result, response)
+	}
+	return result, nil
+}
+
+// apiError responds to a REST API request with an error
+func apiError(w http.ResponseWriter, status int, err error) {
+	apiWrite(w, status, ErrorMessage{Message: err.Error()})
+}
+
+// apiWrite responds to a REST API request with a JSON body
+func apiWrite(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
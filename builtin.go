@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Kappeh/Konnect4/player"
+	"github.com/Kappeh/Konnect4/player/alphabeta"
+	"github.com/Kappeh/Konnect4/player/greedy"
+	"github.com/Kappeh/Konnect4/player/mcts"
+	"github.com/Kappeh/Konnect4/player/random"
+)
+
+// BuiltinPrefix starts the paths of the engines which are written
+// in Go and run within Konnect4, e.g. builtin:random. They're loaded
+// like any other engine but aren't in the engine directory
+const BuiltinPrefix = "builtin:"
+
+// builtinEngines create the engines written in Go
+// which run within Konnect4, by their names
+var builtinEngines = map[string]func() player.Player{
+	"random":    func() player.Player { return random.New() },
+	"greedy":    func() player.Player { return greedy.New() },
+	"alphabeta": func() player.Player { return alphabeta.New() },
+	"mcts":      func() player.Player { return mcts.New() },
+}
+
+// builtinPaths gets the paths that the built-in engines are loaded with
+func builtinPaths() []string {
+	result := make([]string, 0, len(builtinEngines))
+	for name := range builtinEngines {
+		result = append(result, BuiltinPrefix+name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// inProcessTransport runs an engine written in Go within Konnect4
+// It speaks CFP through pipes, so the engine is used like any other
+type inProcessTransport struct {
+	player       player.Player
+	stdinReader  *io.PipeReader
+	stdinWriter  *io.PipeWriter
+	stdoutReader *io.PipeReader
+	stdoutWriter *io.PipeWriter
+	// Anything that goes wrong with the player, such as a panic,
+	// is written to stderr before it exits if stderr is being read
+	stderrReader *io.PipeReader
+	stderrWriter *io.PipeWriter
+	stderr       bool
+	// exited is closed once the player has stopped
+	// being served, with status set before then
+	exited chan struct{}
+	status ExitStatus
+}
+
+// newInProcessTransport creates a transport for a player
+func newInProcessTransport(p player.Player) *inProcessTransport {
+	t := inProcessTransport{player: p, exited: make(chan struct{})}
+	t.stdinReader, t.stdinWriter = io.Pipe()
+	t.stdoutReader, t.stdoutWriter = io.Pipe()
+	t.stderrReader, t.stderrWriter = io.Pipe()
+	return &t
+}
+
+// Pipes returns the pipes which the player is served through
+func (t *inProcessTransport) Pipes() (io.WriteCloser, io.ReadCloser, error) {
+	return t.stdinWriter, t.stdoutReader, nil
+}
+
+// Stderr returns the pipe errors are written to
+func (t *inProcessTransport) Stderr() (io.ReadCloser, error) {
+	t.stderr = true
+	return t.stderrReader, nil
+}
+
+// Start starts serving the player in its own goroutine
+func (t *inProcessTransport) Start() error {
+	go func() {
+		err := player.Serve(t.player, t.stdinReader, t.stdoutWriter)
+		if err != nil {
+			if t.stderr {
+				fmt.Fprintln(t.stderrWriter, err)
+			}
+			t.status.Code = 1
+		}
+		// Anything still writing to the player gets an error
+		t.stdinReader.CloseWithError(io.ErrClosedPipe)
+		t.stdoutWriter.Close()
+		t.stderrWriter.Close()
+		t.status.Time = time.Now()
+		close(t.exited)
+	}()
+	return nil
+}
+
+// Pid is 0 as the player isn't a process
+func (t *inProcessTransport) Pid() int {
+	return 0
+}
+
+// Wait waits for the player to stop being served
+func (t *inProcessTransport) Wait() ExitStatus {
+	<-t.exited
+	return t.status
+}
+
+// Kill stops serving the player as if it had been told to quit
+// Any search the player is running is stopped, so a player which
+// doesn't stop searching when it's told to can't be killed
+func (t *inProcessTransport) Kill() error {
+	t.stdinWriter.Close()
+	t.stdoutReader.CloseWithError(io.ErrClosedPipe)
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// C4NExtension is the extension of files in Connect Four
+// Notation. C4N records games in the same way as PGN does for
+// chess: tag pairs describing the game followed by its moves.
+// Moves are the columns tiles were dropped in, numbered in pairs
+const C4NExtension = ".c4n"
+
+// C4NTag is a tag pair which describes a game
+type C4NTag struct {
+	Name  string
+	Value string
+}
+
+// WriteC4N writes a finished game in Connect Four Notation. The
+// players, starting position, result and termination tags are
+// written after tags
+func WriteC4N(w io.Writer, tags []C4NTag, result GameResult) error {
+	if len(result.History) == 0 {
+		return errors.New("game has no positions")
+	}
+	tags = append(append([]C4NTag{}, tags...),
+		C4NTag{"Player1", result.Player1},
+		C4NTag{"Player2", result.Player2},
+	)
+	start := result.History[0]
+	if start != NewState().CFPString() {
+		tags = append(tags, C4NTag{"Position", start})
+	}
+	tags = append(tags, C4NTag{"Result", c4nResult(result.Winner)})
+	if result.Reason != "" {
+		tags = append(tags, C4NTag{"Termination", result.Reason})
+	}
+	// Writing the tag pairs
+	var b strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&b, "[%s %q]\n", tag.Name, tag.Value)
+	}
+	b.WriteString("\n")
+	// Writing the moves
+	moves, err := movesFromHistory(result.History)
+	if err != nil {
+		return err
+	}
+	first, err := StateFromCFP(start)
+	if err != nil {
+		return errors.Wrap(err, "invalid starting position")
+	}
+	number, player := first.Turn/2+1, first.Player
+	for i, move := range moves {
+		if player == Player1 {
+			fmt.Fprintf(&b, "%d. ", number)
+		} else if i == 0 {
+			fmt.Fprintf(&b, "%d... ", number)
+		}
+		fmt.Fprintf(&b, "%d ", move)
+		if player == Player2 {
+			number++
+		}
+		player = 1 - player
+	}
+	b.WriteString(c4nResult(result.Winner) + "\n\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// c4nResult writes the winner of a game as a C4N result
+func c4nResult(winner int) string {
+	switch winner {
+	case Player1:
+		return "1-0"
+	case Player2:
+		return "0-1"
+	case Tie:
+		return "1/2-1/2"
+	}
+	return "*"
+}
+
+// movesFromHistory works out the column of each move
+// played between the positions of a game in CFP
+func movesFromHistory(history []string) ([]int, error) {
+	result := make([]int, 0, len(history))
+	for i := 1; i < len(history); i++ {
+		before, after := history[i-1], history[i]
+		if len(before) != 43 || len(after) != 43 {
+			return nil, errors.New("invalid position in history")
+		}
+		move := SliceIndex(42, func(j int) bool {
+			return before[j] != after[j]
+		})
+		if move == -1 {
+			return nil, errors.Errorf("no move between positions %d and %d", i-1, i)
+		}
+		result = append(result, move%7)
+	}
+	return result, nil
+}
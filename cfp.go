@@ -4,25 +4,45 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-const (
-	// handshakeTimeout is the maximum amount of time in nanoseconds the
+// DefaultCFPTimeouts are the timeouts used by CFP
+var DefaultCFPTimeouts = CFPTimeouts{
+	Handshake: 5.0 * time.Second,
+	Bestmove:  5.0 * time.Second,
+	Readyok:   5.0 * time.Second,
+}
+
+// CFPTimeouts are the maximum amounts of time an engine is
+// allowed to take to respond to CFP commands
+type CFPTimeouts struct {
+	// Handshake is the maximum amount of time the
 	// engine is allowed to perform the CFP handshake
-	handshakeTimeout = 5.0 * time.Second
-	// bestmoveTimeout is the maximum amount of time in nanoseconds the
-	// engine is allowed to respond to a stop command with bestmove
-	bestmoveTimeout = 5.0 * time.Second
-	// readyokTimeout is the maximum amount of time in nanoseconds the
-	// engine is allowed to respond to an isready command with readyok
-	readyokTimeout = 5.0 * time.Second
-)
+	Handshake time.Duration
+	// Bestmove is the maximum amount of time the engine is
+	// allowed to respond to a stop command with bestmove
+	Bestmove time.Duration
+	// Readyok is the maximum amount of time the engine is
+	// allowed to respond to an isready command with readyok
+	Readyok time.Duration
+}
+
+// UnparseableReplyError is returned when an engine
+// replies to a command with something that can't be parsed
+type UnparseableReplyError struct {
+	Reply string
+}
+
+// Error describes the reply that couldn't be parsed
+func (e UnparseableReplyError) Error() string {
+	return fmt.Sprintf("couldn't parse reply %q", e.Reply)
+}
 
 // CFPProtocol is an interface to an engine that
 // supports CFP. It stores the input and output streams
@@ -38,35 +58,69 @@ type CFPProtocol struct {
 	option chan Option
 	cfpok  chan bool
 	// Other communication channels
-	readyok        chan bool
-	bestmove       chan int
+	readyok  chan bool
+	bestmove chan string
+	// notifyLock guards info and communications and is held
+	// while sending on them, so that nothing is sent on
+	// either once it has been unset
+	notifyLock     sync.Mutex
 	info           chan<- string
 	communications chan<- Communication
+	// closed is closed once the engine's stdout has been closed
+	// which usually means that the process has terminated
+	closed chan struct{}
+	// timeouts are how long the engine is given to respond
+	timeouts CFPTimeouts
+	// requests are the commands waiting on a response
+	// keyed by the response they're waiting on
+	lock     sync.Mutex
+	requests map[string]*cfpRequest
+	// search is the latest structured info sent
+	// during the search, guarded by lock
+	search    SearchInfo
+	hasSearch bool
+	// ponder is the move suggested for pondering
+	// with the last best move, if there was one
+	ponder    int
+	hasPonder bool
 }
 
 // CFP creates a new Protocol that
 // uses the CFP protocol to interact with an engine.
-// cmd should be the engine's process.
+// transport should be the engine's process or connection.
 // An error will be returned if the input and/or output pipes
 // cannot be aquired.
-func CFP(cmd *exec.Cmd) (Protocol, error) {
+func CFP(transport Transport) (Protocol, error) {
+	return NewCFP(DefaultCFPTimeouts)(transport)
+}
+
+// NewCFP returns a function which creates CFP Protocols
+// that give engines the provided timeouts
+func NewCFP(timeouts CFPTimeouts) func(Transport) (Protocol, error) {
+	return func(transport Transport) (Protocol, error) {
+		return newCFPProtocol(transport, timeouts)
+	}
+}
+
+// newCFPProtocol creates a new CFPProtocol for transport
+func newCFPProtocol(transport Transport, timeouts CFPTimeouts) (Protocol, error) {
 	// Make new Protocol along with all channels used
 	// for sending signals around the Protocol
 	result := CFPProtocol{
 		name:     make(chan string),
 		author:   make(chan string),
 		option:   make(chan Option),
-		cfpok:    make(chan bool),
-		readyok:  make(chan bool),
-		bestmove: make(chan int),
+		cfpok:    make(chan bool, 1),
+		readyok:  make(chan bool, 1),
+		bestmove: make(chan string, 1),
+		closed:   make(chan struct{}),
+		timeouts: timeouts,
+		requests: make(map[string]*cfpRequest),
 	}
 	// Aquire stdin and stdout pipes
 	var err error
-	if result.stdin, err = cmd.StdinPipe(); err != nil {
-		return nil, errors.Wrap(err, "couldn't aquire stdin pipe")
-	}
-	if result.stdout, err = cmd.StdoutPipe(); err != nil {
-		return nil, errors.Wrap(err, "couldn't aquire stdout pipe")
+	if result.stdin, result.stdout, err = transport.Pipes(); err != nil {
+		return nil, err
 	}
 	// Return the result
 	return &result, nil
@@ -81,14 +135,20 @@ func (c *CFPProtocol) Handshake(name, author *string, options *map[string]Option
 	// Starts listening for commands from engine
 	go c.listenToEngine()
 	// Send command to initialize handshake
+	c.request("cfpok", TimeoutHandshake, c.timeouts.Handshake)
 	if _, err := c.stdin.Write([]byte("cfp\n")); err != nil {
 		return errors.Wrap(err, "unable to send cfp command")
 	}
 	c.toEngine("cfp\n")
 	var (
-		timeout   = time.After(handshakeTimeout)
+		timeout   = time.After(c.timeouts.Handshake)
 		setName   = false
 		setAuthor = false
+		// Once the timeout has fired, the engine is given
+		// until grace to reply, sending how long it took on late
+		expired *cfpRequest
+		late    <-chan time.Duration
+		grace   <-chan time.Time
 	)
 	// While the handshake is being performed
 	for running := true; running; {
@@ -107,8 +167,20 @@ func (c *CFPProtocol) Handshake(name, author *string, options *map[string]Option
 			// the CFP handshake
 			running = false
 		case <-timeout:
-			// Engine took too long to perform handshake
-			return errors.New("handshake timed out")
+			// Engine took too long to perform handshake unless it
+			// finished just as the timeout fired. It's given a little
+			// longer so that how long it takes can be reported
+			timeout = nil
+			if expired = c.expire("cfpok"); expired != nil {
+				late, grace = expired.late, time.After(lateReplyGrace)
+			}
+		case took := <-late:
+			return expired.timeoutError(took, true)
+		case <-grace:
+			return expired.timeoutError(time.Since(expired.sent), false)
+		case <-c.closed:
+			// Engine terminated during the handshake
+			return errors.New("engine disconnected during handshake")
 		}
 	}
 	// A name and author is required. If either is not
@@ -221,8 +293,61 @@ func (c *CFPProtocol) Position(s State) error {
 // Go Tells the engine that it should start analysing the
 // last position it was sent. In addition to this,
 // if moveTime is positive, the engine will be told to
-// complete it's move within the given time.
-func (c *CFPProtocol) Go(moveTime time.Duration) error {
+// complete it's move within the given time. Any limits
+// are sent after the move time.
+func (c *CFPProtocol) Go(moveTime time.Duration, limits SearchLimits) error {
+	// Check engine is ready for commands
+	if err := c.waitForReady(); err != nil {
+		return errors.Wrap(err, "engine not ready")
+	}
+	// Generating command to send
+	args := []string{"go"}
+	if moveTime > 0.0 {
+		args = append(args, fmt.Sprintf("movetime %f", float64(moveTime)/float64(time.Second)))
+	}
+	if limits.Limited() {
+		args = append(args, limits.String())
+	}
+	cmd := strings.Join(args, " ") + "\n"
+	// Forgetting the info from the previous search
+	c.lock.Lock()
+	c.search, c.hasSearch = SearchInfo{}, false
+	c.lock.Unlock()
+	// Sending command
+	if _, err := c.stdin.Write([]byte(cmd)); err != nil {
+		return errors.Wrap(err, "couldn't send go command")
+	}
+	c.toEngine(cmd)
+	// Command successfully sent
+	return nil
+}
+
+// GoPonder tells the engine to start analysing the last position
+// it was sent as if move had been played in it, while its opponent
+// is thinking. The engine is then either told that the move was
+// played with PonderHit or that it wasn't with Stop
+func (c *CFPProtocol) GoPonder(move int) error {
+	// Check engine is ready for commands
+	if err := c.waitForReady(); err != nil {
+		return errors.Wrap(err, "engine not ready")
+	}
+	// Forgetting the info from the previous search
+	c.lock.Lock()
+	c.search, c.hasSearch = SearchInfo{}, false
+	c.lock.Unlock()
+	// Sending command
+	cmd := fmt.Sprintf("go ponder %d\n", move)
+	if _, err := c.stdin.Write([]byte(cmd)); err != nil {
+		return errors.Wrap(err, "couldn't send go ponder command")
+	}
+	c.toEngine(cmd)
+	return nil
+}
+
+// PonderHit tells a pondering engine that the move it was
+// pondering on has been played. If moveTime is positive, the
+// engine will be told to complete it's move within the given time
+func (c *CFPProtocol) PonderHit(moveTime time.Duration) error {
 	// Check engine is ready for commands
 	if err := c.waitForReady(); err != nil {
 		return errors.Wrap(err, "engine not ready")
@@ -230,42 +355,72 @@ func (c *CFPProtocol) Go(moveTime time.Duration) error {
 	// Generating command to send
 	var cmd string
 	if moveTime <= 0.0 {
-		cmd = "go\n"
+		cmd = "ponderhit\n"
 	} else {
-		cmd = fmt.Sprintf("go movetime %f\n", float64(moveTime)/float64(time.Second))
+		cmd = fmt.Sprintf("ponderhit movetime %f\n", float64(moveTime)/float64(time.Second))
 	}
 	// Sending command
 	if _, err := c.stdin.Write([]byte(cmd)); err != nil {
-		return errors.Wrap(err, "couldn't send go command")
+		return errors.Wrap(err, "couldn't send ponderhit command")
 	}
 	c.toEngine(cmd)
-	// Command successfully sent
 	return nil
 }
 
 // Stop tells the engine to stop analysing it's position
 // and return the best move that it found
 // If the engine doesn't provide a best move, an
-// error will be returned
+// error will be returned. If the best move isn't a
+// number, the error is an UnparseableReplyError
 func (c *CFPProtocol) Stop() (int, error) {
 	// Check engine is ready for commands
 	if err := c.waitForReady(); err != nil {
 		return 0, errors.Wrap(err, "engine not ready")
 	}
-	// Send stop command
+	// Send stop command, discarding any best move
+	// that arrived after a previous stop timed out
+	select {
+	case <-c.bestmove:
+	default:
+	}
+	c.request("bestmove", TimeoutBestmove, c.timeouts.Bestmove)
 	if _, err := c.stdin.Write([]byte("stop\n")); err != nil {
 		return 0, errors.Wrap(err, "couldn't send stop command")
 	}
 	c.toEngine("stop\n")
 	// Wait on bestmove command from engine
+	var v string
 	select {
-	case v := <-c.bestmove:
-		// Return the best move
-		return v, nil
-	case <-time.After(bestmoveTimeout):
+	case v = <-c.bestmove:
+	case <-time.After(c.timeouts.Bestmove):
 		// Engine didn't send best move in time
-		return 0, errors.New("bestmove timed out")
+		// unless it sent it just as the timeout fired
+		if err := c.timeout("bestmove"); err != nil {
+			return 0, err
+		}
+		v = <-c.bestmove
+	case <-c.closed:
+		// Engine terminated before sending best move
+		return 0, errors.New("engine disconnected")
+	}
+	// Return the best move, keeping the
+	// move suggested for pondering
+	args := strings.Fields(v)
+	if len(args) == 0 {
+		return 0, UnparseableReplyError{Reply: "bestmove"}
+	}
+	move, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, UnparseableReplyError{Reply: "bestmove " + v}
+	}
+	c.lock.Lock()
+	c.hasPonder = false
+	if len(args) >= 3 && strings.ToLower(args[1]) == "ponder" {
+		c.ponder, err = strconv.Atoi(args[2])
+		c.hasPonder = err == nil
 	}
+	c.lock.Unlock()
+	return move, nil
 }
 
 // Quit tells the engine to quit as soon as possible and
@@ -292,20 +447,42 @@ func (c *CFPProtocol) Quit() error {
 	return nil
 }
 
+// PonderMove gets the move the engine suggested
+// pondering on along with its last best move
+func (c *CFPProtocol) PonderMove() (int, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.ponder, c.hasPonder
+}
+
+// LastInfo gets the latest structured info
+// the engine sent during its search
+func (c *CFPProtocol) LastInfo() (SearchInfo, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.search, c.hasSearch
+}
+
 // NotifyInfo sets the channel in which any info commands
 // from the engine should be send to
 func (c *CFPProtocol) NotifyInfo(channel chan<- string) {
+	c.notifyLock.Lock()
+	defer c.notifyLock.Unlock()
 	c.info = channel
 }
 
 // NotifyComm sets the channel in which any communications
 // between CFP and the engine are to be sent
 func (c *CFPProtocol) NotifyComm(channel chan<- Communication) {
+	c.notifyLock.Lock()
+	defer c.notifyLock.Unlock()
 	c.communications = channel
 }
 
 // fromEngine adds a communication to the communications channel
 func (c *CFPProtocol) fromEngine(message string) {
+	c.notifyLock.Lock()
+	defer c.notifyLock.Unlock()
 	if c.communications == nil {
 		return
 	}
@@ -318,6 +495,8 @@ func (c *CFPProtocol) fromEngine(message string) {
 
 // toEngine adds a communication to the communications channel
 func (c *CFPProtocol) toEngine(message string) {
+	c.notifyLock.Lock()
+	defer c.notifyLock.Unlock()
 	if c.communications == nil {
 		return
 	}
@@ -340,25 +519,42 @@ func (c *CFPProtocol) listenToEngine() {
 		c.fromEngine(text)
 		c.receivedCommand(text)
 	}
+	// The engine's output has closed, anything waiting on
+	// a response from the engine will never receive one
+	close(c.closed)
 }
 
 // waitForReady sends an isready command to the engine
 // and waits until the engine responds with a readyok command
 // If the engine takes too long, an error will be returned
 func (c *CFPProtocol) waitForReady() error {
-	// Send isready command
+	// Send isready command, discarding any readyok
+	// that arrived after a previous isready timed out
+	select {
+	case <-c.readyok:
+	default:
+	}
+	c.request("readyok", TimeoutReadyok, c.timeouts.Readyok)
 	if _, err := c.stdin.Write([]byte("isready\n")); err != nil {
 		return errors.Wrap(err, "unable to send isready command")
 	}
 	c.toEngine("isready\n")
 	// Wait for response or timeout
 	select {
-	case <-time.After(readyokTimeout):
-		// Engine took too long to respond
-		return errors.New("engine took too long to respond")
+	case <-time.After(c.timeouts.Readyok):
+		// Engine took too long to respond unless
+		// it responded just as the timeout fired
+		if err := c.timeout("readyok"); err != nil {
+			return err
+		}
+		<-c.readyok
+		return nil
 	case <-c.readyok:
 		// Engine responded
 		return nil
+	case <-c.closed:
+		// Engine terminated before responding
+		return errors.New("engine disconnected")
 	}
 }
 
@@ -375,9 +571,13 @@ func (c *CFPProtocol) receivedCommand(msg string) {
 	case "id":
 		c.receivedIDCommand(args[1:])
 	case "cfpok":
-		c.cfpok <- true
+		if c.respond("cfpok") {
+			c.cfpok <- true
+		}
 	case "readyok":
-		c.readyok <- true
+		if c.respond("readyok") {
+			c.readyok <- true
+		}
 	case "bestmove":
 		c.receivedBestMoveCommand(args[1:])
 	case "info":
@@ -403,20 +603,27 @@ func (c *CFPProtocol) receivedIDCommand(args []string) {
 
 // receivedIDCommand is called when a bestmove command is received
 // from the engine
+// The move is parsed by Stop so that a reply which
+// can't be parsed is reported rather than ignored
 func (c *CFPProtocol) receivedBestMoveCommand(args []string) {
-	if len(args) < 1 {
-		return
+	if c.respond("bestmove") {
+		c.bestmove <- strings.Join(args, " ")
 	}
-	move, err := strconv.Atoi(args[0])
-	if err != nil {
-		return
-	}
-	c.bestmove <- move
 }
 
 // receivedIDCommand is called when an info command is received
 // from the engine
+// Structured info about the engine's best move is kept so that
+// the search can be followed. Info about its other moves, when
+// it's reporting on several, is only passed on
 func (c *CFPProtocol) receivedInfoCommand(args []string) {
+	if info, ok := ParseSearchInfo(args); ok && info.Best() {
+		c.lock.Lock()
+		c.search, c.hasSearch = info, true
+		c.lock.Unlock()
+	}
+	c.notifyLock.Lock()
+	defer c.notifyLock.Unlock()
 	if len(args) < 1 || c.info == nil {
 		return
 	}
@@ -425,43 +632,43 @@ func (c *CFPProtocol) receivedInfoCommand(args []string) {
 
 // receivedOptionCommand is called whenever the engine
 // has specified an internal parameter that can be changed
-// The command arguments are used to determine which option type
-// is being specified and then calls the respective parsing function
 // Note: As specified in the CFP protocol, if the command
 // cannot be parsed, it is ignored.
 func (c *CFPProtocol) receivedOptionCommand(args []string) {
+	option, err := c.parseOption(args)
+	// If an error occured, ignore the command
+	if err != nil {
+		return
+	}
+	// Otherwise, send parsed option to be handled
+	c.option <- option
+}
+
+// parseOption parses the arguments of an option command
+// The command arguments are used to determine which option type
+// is being specified and then calls the respective parsing function
+func (c *CFPProtocol) parseOption(args []string) (Option, error) {
 	// Getting index of type identifier
 	typeIndex := SliceIndex(len(args), func(i int) bool {
 		return strings.ToLower(args[i]) == "type"
 	})
-	if typeIndex == -1 {
-		return
+	if typeIndex == -1 || typeIndex == len(args)-1 {
+		return nil, errors.New("option has no type")
 	}
-	var (
-		option Option
-		err    error
-	)
 	// Calling parsing function depending on type
 	switch strings.ToLower(args[typeIndex+1]) {
 	case "check":
-		option, err = c.checkOption(args[:])
+		return c.checkOption(args[:])
 	case "spin":
-		option, err = c.spinOption(args[:])
+		return c.spinOption(args[:])
 	case "button":
-		option, err = c.buttonOption(args[:])
+		return c.buttonOption(args[:])
 	case "combo":
-		option, err = c.comboOption(args[:])
+		return c.comboOption(args[:])
 	case "string":
-		option, err = c.stringOption(args[:])
-	default:
-		return
+		return c.stringOption(args[:])
 	}
-	// If an error occured, ignore the command
-	if err != nil {
-		return
-	}
-	// Otherwise, send parsed option to be handled
-	c.option <- option
+	return nil, errors.Errorf("unknown option type %s", args[typeIndex+1])
 }
 
 // Parameter is used to group keywords into
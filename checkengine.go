@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// checkThinkTime is how long an engine is left
+	// to think during a check before it is interrupted
+	checkThinkTime = 200 * time.Millisecond
+	// checkQuietTime is how long an engine is watched for output
+	// which it shouldn't send, e.g. a bestmove without a search
+	checkQuietTime = 200 * time.Millisecond
+)
+
+// runCheckEngine drives an engine through a series of checks of
+// how it follows CFP, printing whether each check passed along
+// with the communication of each check that failed. The exit
+// code is non-zero if any of the checks failed
+func runCheckEngine(args []string) int {
+	flags := flag.NewFlagSet(ApplicationName+" check-engine", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check-engine [flags] <path>\n", ApplicationName)
+		fmt.Fprintln(flags.Output(), "path is the engine, RELATIVE to the engine directory")
+		flags.PrintDefaults()
+	}
+	configuration := addConfigFlags(flags)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	config, err := configuration.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.UseDirectories()
+	definition, err := LoadDefinition(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "couldn't load engine definition"))
+		return 1
+	}
+	if _, err := definition.executable(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Checking %s\n", flags.Arg(0))
+	if !checkEngine(os.Stdout, definition, config.CFPTimeouts()) {
+		return 1
+	}
+	return 0
+}
+
+// checkEngine runs every check against an engine, writing
+// the report to w. Each check is given a new engine process
+// It returns whether all of the checks passed
+func checkEngine(w io.Writer, definition EngineDefinition, timeouts CFPTimeouts) bool {
+	timeouts = definition.Timeouts.CFP(timeouts)
+	quit := QuitGracePeriod
+	if definition.Timeouts.Quit > 0 {
+		quit = time.Duration(definition.Timeouts.Quit)
+	}
+	checks := engineChecks()
+	passed := 0
+	for _, check := range checks {
+		s, err := newCheckSession(definition, timeouts, quit)
+		if err == nil {
+			err = check.run(s)
+			s.close()
+		}
+		if err == nil {
+			passed++
+			if s.detail != "" {
+				fmt.Fprintf(w, "PASS %s (%s)\n", check.name, s.detail)
+			} else {
+				fmt.Fprintf(w, "PASS %s\n", check.name)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "FAIL %s: %s\n", check.name, err)
+		if s != nil {
+			for _, line := range s.transcript {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d of %d checks passed\n", passed, len(checks))
+	return passed == len(checks)
+}
+
+// engineCheck is a single check of how an engine follows CFP
+type engineCheck struct {
+	name string
+	// run drives the engine through the check, returning
+	// why the engine failed the check if it did
+	run func(s *checkSession) error
+}
+
+// engineChecks returns every check of the check-engine command
+func engineChecks() []engineCheck {
+	return []engineCheck{
+		{"handshake ordering", checkHandshake},
+		{"option syntax", checkOptions},
+		{"isready while thinking", checkReadyWhileThinking},
+		{"stop with no search running", checkStopWithoutSearch},
+		{"unknown commands ignored", checkUnknownCommands},
+		{"bestmove legality", checkBestmove},
+		{"debug toggling", checkDebug},
+		{"quit latency", checkQuit},
+	}
+}
+
+// checkHandshake checks that the engine identifies itself before
+// sending its options and finishes the handshake with cfpok
+func checkHandshake(s *checkSession) error {
+	lines, err := s.handshake()
+	if err != nil {
+		return err
+	}
+	var name, author, options bool
+	for _, line := range lines {
+		args := strings.Fields(line)
+		switch args[0] {
+		case "id":
+			if options {
+				return errors.New("id was sent after an option")
+			}
+			if len(args) > 2 && args[1] == "name" {
+				name = true
+			} else if len(args) > 2 && args[1] == "author" {
+				author = true
+			}
+		case "option":
+			options = true
+		}
+	}
+	if !name {
+		return errors.New("no id name was sent")
+	}
+	if !author {
+		return errors.New("no id author was sent")
+	}
+	return s.ready()
+}
+
+// checkOptions checks that every option
+// sent during the handshake can be parsed
+func checkOptions(s *checkSession) error {
+	lines, err := s.handshake()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, line := range lines {
+		args := strings.Fields(line)
+		if args[0] != "option" {
+			continue
+		}
+		option, err := (&CFPProtocol{}).parseOption(args[1:])
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse %q", line)
+		}
+		name := strings.ToLower(option.OptionName())
+		if names[name] {
+			return errors.Errorf("option %s was sent more than once", option.OptionName())
+		}
+		names[name] = true
+		if name == strings.ToLower(MultiPVOption) {
+			if err := checkMultiPVOption(option); err != nil {
+				return errors.Wrapf(err, "invalid %q", line)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMultiPVOption checks that the standard MultiPV option is
+// a spin from 1, defaulting to 1, with at most one line per column
+func checkMultiPVOption(option Option) error {
+	spinner, ok := option.(Spinner)
+	if !ok {
+		return errors.Errorf("%s must be a spin option", MultiPVOption)
+	}
+	if spinner.Min != 1 || spinner.Value != 1 {
+		return errors.Errorf("%s must have a default and minimum of 1", MultiPVOption)
+	}
+	if spinner.Max < 1 || spinner.Max > 7 {
+		return errors.Errorf("%s must have a maximum between 1 and 7", MultiPVOption)
+	}
+	return nil
+}
+
+// checkReadyWhileThinking checks that isready is
+// answered straight away while the engine is thinking
+func checkReadyWhileThinking(s *checkSession) error {
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	if err := s.search(NewState()); err != nil {
+		return err
+	}
+	time.Sleep(checkThinkTime)
+	if err := s.ready(); err != nil {
+		return errors.Wrap(err, "while thinking")
+	}
+	_, err := s.stop(NewState())
+	return err
+}
+
+// checkStopWithoutSearch checks that a stop
+// is ignored when the engine isn't thinking
+func checkStopWithoutSearch(s *checkSession) error {
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	if err := s.ready(); err != nil {
+		return err
+	}
+	if err := s.send("position " + NewState().CFPString()); err != nil {
+		return err
+	}
+	if err := s.send("stop"); err != nil {
+		return err
+	}
+	for _, line := range s.quiet(checkQuietTime) {
+		if strings.HasPrefix(line, "bestmove") {
+			return errors.New("bestmove was sent without a search")
+		}
+	}
+	return s.ready()
+}
+
+// checkUnknownCommands checks that unknown commands are ignored
+// and that unknown tokens before a command are skipped over
+func checkUnknownCommands(s *checkSession) error {
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	if err := s.send("xyzzy plugh"); err != nil {
+		return err
+	}
+	if err := s.ready(); err != nil {
+		return errors.Wrap(err, "after an unknown command")
+	}
+	if err := s.send("xyzzy isready"); err != nil {
+		return err
+	}
+	if _, err := s.expect("readyok", s.timeouts.Readyok); err != nil {
+		return errors.Wrap(err, "unknown token before isready wasn't skipped")
+	}
+	return nil
+}
+
+// checkBestmove checks that the engine gives legal moves in
+// positions where some of the columns are already full
+func checkBestmove(s *checkSession) error {
+	positions := []State{NewState()}
+	// A position where the first and last columns are full
+	state := NewState()
+	for _, column := range []int{0, 0, 0, 0, 0, 0, 6, 6, 6, 6, 6, 6} {
+		state, _ = state.NextState(column)
+	}
+	positions = append(positions, state)
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	for _, position := range positions {
+		if err := s.search(position); err != nil {
+			return err
+		}
+		time.Sleep(checkThinkTime)
+		if _, err := s.stop(position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDebug checks that the engine keeps responding after debug
+// mode is switched on and off, including while it is thinking
+func checkDebug(s *checkSession) error {
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	if err := s.send("debug on"); err != nil {
+		return err
+	}
+	if err := s.search(NewState()); err != nil {
+		return errors.Wrap(err, "with debug on")
+	}
+	if err := s.send("debug off"); err != nil {
+		return err
+	}
+	if err := s.ready(); err != nil {
+		return errors.Wrap(err, "after debug off while thinking")
+	}
+	_, err := s.stop(NewState())
+	return err
+}
+
+// checkQuit checks that the engine exits
+// soon after it is told to quit
+func checkQuit(s *checkSession) error {
+	if _, err := s.handshake(); err != nil {
+		return err
+	}
+	if err := s.ready(); err != nil {
+		return err
+	}
+	if err := s.send("quit"); err != nil {
+		return err
+	}
+	start := time.Now()
+	deadline := time.After(s.quit)
+	for {
+		// Anything the engine sends is recorded while it quits
+		if _, err := s.receive(deadline); err == errTimeout {
+			return errors.Errorf("engine didn't exit within %s of quit", s.quit)
+		} else if err != nil {
+			break
+		}
+	}
+	<-s.exited
+	s.detail = fmt.Sprintf("exited after %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// checkSession is the communication with an engine process
+// during a check. Everything sent to and received from the
+// engine is recorded in the transcript
+type checkSession struct {
+	transport Transport
+	stdin     io.WriteCloser
+	// lines receives each line the engine writes to stdout
+	lines  <-chan string
+	exited chan struct{}
+	// timeouts are how long the engine is given to respond
+	timeouts CFPTimeouts
+	quit     time.Duration
+	// transcript is the communication with the engine, each
+	// line prefixed with the time since the session started
+	start      time.Time
+	transcript []string
+	// detail is reported along with a check which passed
+	detail string
+}
+
+// newCheckSession starts or connects to an engine for a check
+func newCheckSession(definition EngineDefinition, timeouts CFPTimeouts, quit time.Duration) (*checkSession, error) {
+	path, err := definition.executable()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := definition.transport(path)
+	if err != nil {
+		return nil, err
+	}
+	stdin, stdout, err := transport.Pipes()
+	if err != nil {
+		return nil, err
+	}
+	if err := transport.Start(); err != nil {
+		return nil, errors.Wrap(err, "couldn't start engine")
+	}
+	lines := make(chan string, EventBufferSize)
+	s := &checkSession{
+		transport: transport,
+		stdin:     stdin,
+		lines:     lines,
+		exited:    make(chan struct{}),
+		timeouts:  timeouts,
+		quit:      quit,
+		start:     time.Now(),
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+		transport.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+// close kills the engine process if it is still running
+func (s *checkSession) close() {
+	s.stdin.Close()
+	select {
+	case <-s.exited:
+	default:
+		s.transport.Kill()
+		// The output is drained so that it can be closed
+		for range s.lines {
+		}
+		<-s.exited
+	}
+}
+
+// record adds a line to the transcript
+func (s *checkSession) record(direction, line string) {
+	elapsed := time.Since(s.start).Seconds()
+	s.transcript = append(s.transcript, fmt.Sprintf("%7.3fs %s %s", elapsed, direction, line))
+}
+
+// send sends a command to the engine
+func (s *checkSession) send(command string) error {
+	select {
+	case <-s.exited:
+		return errors.Errorf("engine exited before %s could be sent", command)
+	default:
+	}
+	s.record(">", command)
+	if _, err := io.WriteString(s.stdin, command+"\n"); err != nil {
+		return errors.Wrapf(err, "couldn't send %s", command)
+	}
+	return nil
+}
+
+// receive waits for the next line from the engine
+func (s *checkSession) receive(timeout <-chan time.Time) (string, error) {
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			s.record("#", "engine exited")
+			return "", errors.New("engine exited")
+		}
+		s.record("<", line)
+		return line, nil
+	case <-timeout:
+		return "", errTimeout
+	}
+}
+
+// errTimeout is returned by receive when no line was
+// received from the engine before the timeout
+var errTimeout = errors.New("timed out")
+
+// expect waits for a command from the engine. Every line
+// received up to and including the command is returned
+func (s *checkSession) expect(command string, timeout time.Duration) ([]string, error) {
+	deadline := time.After(timeout)
+	var result []string
+	for {
+		line, err := s.receive(deadline)
+		if err == errTimeout {
+			return result, errors.Errorf("no %s within %s", command, timeout)
+		} else if err != nil {
+			return result, errors.Wrapf(err, "waiting for %s", command)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		result = append(result, line)
+		if fields[0] == command {
+			return result, nil
+		}
+	}
+}
+
+// quiet collects the lines the engine sends for a duration
+func (s *checkSession) quiet(duration time.Duration) []string {
+	deadline := time.After(duration)
+	var result []string
+	for {
+		line, err := s.receive(deadline)
+		if err != nil {
+			return result
+		}
+		result = append(result, line)
+	}
+}
+
+// handshake performs the CFP handshake. The lines the
+// engine sent before cfpok are returned
+func (s *checkSession) handshake() ([]string, error) {
+	if err := s.send("cfp"); err != nil {
+		return nil, err
+	}
+	lines, err := s.expect("cfpok", s.timeouts.Handshake)
+	if err != nil {
+		return nil, err
+	}
+	return lines[:len(lines)-1], nil
+}
+
+// ready checks that the engine responds to isready
+func (s *checkSession) ready() error {
+	if err := s.send("isready"); err != nil {
+		return err
+	}
+	lines, err := s.expect("readyok", s.timeouts.Readyok)
+	if err != nil {
+		return err
+	}
+	return s.unexpectedBestmove(lines)
+}
+
+// search starts the engine thinking about a position
+// from a new game, making sure it's ready beforehand
+func (s *checkSession) search(position State) error {
+	if err := s.send("cfpnewgame"); err != nil {
+		return err
+	}
+	if err := s.ready(); err != nil {
+		return err
+	}
+	if err := s.send("position " + position.CFPString()); err != nil {
+		return err
+	}
+	return s.send("go")
+}
+
+// stop stops the engine thinking and checks that
+// its bestmove is a legal move in the position
+func (s *checkSession) stop(position State) (int, error) {
+	if err := s.send("stop"); err != nil {
+		return 0, err
+	}
+	lines, err := s.expect("bestmove", s.timeouts.Bestmove)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 2 {
+		return 0, errors.New("bestmove has no move")
+	}
+	move, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, errors.Errorf("bestmove %s isn't a column", fields[1])
+	}
+	if _, err := position.NextState(move); err != nil {
+		return 0, errors.Errorf("bestmove %d is illegal", move)
+	}
+	return move, nil
+}
+
+// unexpectedBestmove checks that none of the lines are
+// a bestmove, which should only be sent after a stop
+func (s *checkSession) unexpectedBestmove(lines []string) error {
+	for _, line := range lines {
+		if strings.HasPrefix(line, "bestmove") {
+			return errors.New("bestmove was sent before stop")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// Command alphabeta runs the alphabeta engine on its own, speaking CFP
+// on stdin and stdout so it can be put in the engine directory
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Kappeh/Konnect4/player"
+	"github.com/Kappeh/Konnect4/player/alphabeta"
+)
+
+func main() {
+	if err := player.Serve(alphabeta.New(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
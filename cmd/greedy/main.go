@@ -0,0 +1,18 @@
+// Command greedy runs the greedy engine on its own, speaking CFP
+// on stdin and stdout so it can be put in the engine directory
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Kappeh/Konnect4/player"
+	"github.com/Kappeh/Konnect4/player/greedy"
+)
+
+func main() {
+	if err := player.Serve(greedy.New(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -1,9 +1,298 @@
 package main
 
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 const (
 	// ApplicationName is the name of the application, DUH!
 	ApplicationName = "Konnect4"
+	// DefaultConfigFile is the file RELATIVE to the app which
+	// the configuration is read from if no other file is given
+	DefaultConfigFile = "konnect4.json"
+	// ConfigEnvPrefix is the prefix of the environment
+	// variables which override the configuration
+	ConfigEnvPrefix = "KONNECT4_"
+)
+
+var (
 	// EngineDirectory is the directory RELATIVE to the app
 	// in which the engines are to be found
 	EngineDirectory = "engines"
+	// LogDirectory is the directory RELATIVE to the app
+	// in which the per-game logs are written
+	LogDirectory = "logs"
+	// ProfileDirectory is the directory RELATIVE to the app
+	// in which engine profiles are saved
+	ProfileDirectory = "profiles"
+	// SessionFile is the file RELATIVE to the app in which
+	// the session is saved so it can be restored
+	SessionFile = "session.json"
 )
+
+// Config holds all of the settings which can be changed
+// without rebuilding the application. Settings are read from
+// a JSON file, then environment variables and then
+// command-line flags, each overriding the last
+type Config struct {
+	// Address is the address the server listens on
+	Address string `json:"address"`
+	// MaxConnections is the maximum number of WebSockets
+	// that can be connected at once
+	MaxConnections int `json:"maxconnections"`
+	// EngineDirectory, LogDirectory, ProfileDirectory and
+	// SessionFile are where everything is found and saved
+	EngineDirectory  string `json:"enginedirectory"`
+	LogDirectory     string `json:"logdirectory"`
+	ProfileDirectory string `json:"profiledirectory"`
+	SessionFile      string `json:"sessionfile"`
+	// TurnTime is the default time players are given to
+	// analyse a position
+	TurnTime Duration `json:"turntime"`
+	// HandshakeTimeout, ReadyokTimeout and BestmoveTimeout
+	// are how long engines are given to respond to CFP commands
+	HandshakeTimeout Duration `json:"handshaketimeout"`
+	ReadyokTimeout   Duration `json:"readyoktimeout"`
+	BestmoveTimeout  Duration `json:"bestmovetimeout"`
+	// LegacyProtocol enables the text protocol which was used
+	// before the JSON protocol, for clients which haven't moved over
+	LegacyProtocol bool `json:"legacyprotocol"`
+}
+
+// configSetting is a setting which can be overridden
+// by an environment variable or a command-line flag
+type configSetting struct {
+	// name is the name of the flag. The environment variable
+	// is the name in upper case with ConfigEnvPrefix
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// DefaultConfig returns the configuration that is used
+// for anything which isn't set
+func DefaultConfig() Config {
+	return Config{
+		Address:          ":8080",
+		MaxConnections:   100,
+		EngineDirectory:  "engines",
+		LogDirectory:     "logs",
+		ProfileDirectory: "profiles",
+		SessionFile:      "session.json",
+		TurnTime:         Duration(DefaultTurnTime),
+		HandshakeTimeout: Duration(DefaultCFPTimeouts.Handshake),
+		ReadyokTimeout:   Duration(DefaultCFPTimeouts.Readyok),
+		BestmoveTimeout:  Duration(DefaultCFPTimeouts.Bestmove),
+	}
+}
+
+// LoadConfig reads the configuration using the command-line
+// arguments, which don't include the program name.
+// The config file is given by the config flag. If it isn't
+// given and DefaultConfigFile doesn't exist, only environment
+// variables and flags are used
+func LoadConfig(args []string) (Config, error) {
+	flags := flag.NewFlagSet(ApplicationName, flag.ContinueOnError)
+	config := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+	return config.load()
+}
+
+// configFlags are the flags of a flag set which
+// give the config file and override its settings
+type configFlags struct {
+	flags  *flag.FlagSet
+	path   *string
+	values map[string]*string
+}
+
+// addConfigFlags defines the config flag and a flag for each of
+// the settings in flags, so that subcommands can be configured
+// Settings which flags already has a flag for, e.g. a
+// subcommand's own address, can't be overridden by flags
+func addConfigFlags(flags *flag.FlagSet) configFlags {
+	result := configFlags{flags: flags, values: make(map[string]*string)}
+	result.path = flags.String("config", "", "path to the config file (default "+DefaultConfigFile+")")
+	for _, s := range configSettings() {
+		if flags.Lookup(s.name) == nil {
+			result.values[s.name] = flags.String(s.name, "", s.usage)
+		}
+	}
+	return result
+}
+
+// load reads the configuration once the flags have been parsed
+func (c configFlags) load() (Config, error) {
+	result := DefaultConfig()
+	settings := configSettings()
+	// Read the config file
+	path := *c.path
+	if path == "" {
+		path = os.Getenv(ConfigEnvPrefix + "CONFIG")
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultConfigFile); err == nil {
+			path = DefaultConfigFile
+		}
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Config{}, errors.Wrap(err, "couldn't read config file")
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return Config{}, errors.Wrap(err, "couldn't parse config file")
+		}
+	}
+	// Apply environment variables
+	for _, s := range settings {
+		env := ConfigEnvPrefix + strings.ToUpper(s.name)
+		if value, ok := os.LookupEnv(env); ok {
+			if err := s.set(&result, value); err != nil {
+				return Config{}, errors.Wrapf(err, "invalid %s", env)
+			}
+		}
+	}
+	// Apply the flags which have been given
+	var err error
+	c.flags.Visit(func(f *flag.Flag) {
+		value, ok := c.values[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		for _, s := range settings {
+			if s.name == f.Name {
+				if e := s.set(&result, *value); e != nil {
+					err = errors.Wrapf(e, "invalid -%s", s.name)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	if err := result.Validate(); err != nil {
+		return Config{}, errors.Wrap(err, "invalid config")
+	}
+	return result, nil
+}
+
+// configSettings returns the settings which can be
+// overridden along with how to set them
+func configSettings() []configSetting {
+	defaults := DefaultConfig()
+	setString := func(field func(*Config) *string) func(*Config, string) error {
+		return func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		}
+	}
+	setDuration := func(field func(*Config) *Duration) func(*Config, string) error {
+		return func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = Duration(d)
+			return nil
+		}
+	}
+	return []configSetting{
+		{"address", fmt.Sprintf("address the server listens on (default %q)", defaults.Address),
+			setString(func(c *Config) *string { return &c.Address })},
+		{"maxconnections", fmt.Sprintf("maximum number of WebSocket connections (default %d)", defaults.MaxConnections),
+			func(c *Config, value string) error {
+				n, err := strconv.Atoi(value)
+				c.MaxConnections = n
+				return err
+			}},
+		{"enginedirectory", fmt.Sprintf("directory of the engines (default %q)", defaults.EngineDirectory),
+			setString(func(c *Config) *string { return &c.EngineDirectory })},
+		{"logdirectory", fmt.Sprintf("directory of the game logs (default %q)", defaults.LogDirectory),
+			setString(func(c *Config) *string { return &c.LogDirectory })},
+		{"profiledirectory", fmt.Sprintf("directory of the engine profiles (default %q)", defaults.ProfileDirectory),
+			setString(func(c *Config) *string { return &c.ProfileDirectory })},
+		{"sessionfile", fmt.Sprintf("file the session is saved in (default %q)", defaults.SessionFile),
+			setString(func(c *Config) *string { return &c.SessionFile })},
+		{"turntime", fmt.Sprintf("default time given for each move (default %s)", time.Duration(defaults.TurnTime)),
+			setDuration(func(c *Config) *Duration { return &c.TurnTime })},
+		{"handshaketimeout", fmt.Sprintf("time given for the CFP handshake (default %s)", time.Duration(defaults.HandshakeTimeout)),
+			setDuration(func(c *Config) *Duration { return &c.HandshakeTimeout })},
+		{"readyoktimeout", fmt.Sprintf("time given to respond to isready (default %s)", time.Duration(defaults.ReadyokTimeout)),
+			setDuration(func(c *Config) *Duration { return &c.ReadyokTimeout })},
+		{"bestmovetimeout", fmt.Sprintf("time given to respond to stop (default %s)", time.Duration(defaults.BestmoveTimeout)),
+			setDuration(func(c *Config) *Duration { return &c.BestmoveTimeout })},
+		{"legacyprotocol", "accept clients using the legacy text protocol on /ws/legacy (default false)",
+			func(c *Config, value string) error {
+				b, err := strconv.ParseBool(value)
+				c.LegacyProtocol = b
+				return err
+			}},
+	}
+}
+
+// Validate makes sure that every setting has a usable value
+func (c Config) Validate() error {
+	if _, port, err := net.SplitHostPort(c.Address); err != nil {
+		return errors.Wrap(err, "invalid address")
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return errors.Errorf("invalid port %s", port)
+	}
+	if c.MaxConnections <= 0 {
+		return errors.New("maxconnections must be positive")
+	}
+	directories := map[string]string{
+		"enginedirectory":  c.EngineDirectory,
+		"logdirectory":     c.LogDirectory,
+		"profiledirectory": c.ProfileDirectory,
+		"sessionfile":      c.SessionFile,
+	}
+	for name, value := range directories {
+		if strings.TrimSpace(value) == "" {
+			return errors.Errorf("%s can't be empty", name)
+		}
+	}
+	durations := map[string]Duration{
+		"turntime":         c.TurnTime,
+		"handshaketimeout": c.HandshakeTimeout,
+		"readyoktimeout":   c.ReadyokTimeout,
+		"bestmovetimeout":  c.BestmoveTimeout,
+	}
+	for name, value := range durations {
+		if value <= 0 {
+			return errors.Errorf("%s must be positive", name)
+		}
+	}
+	return nil
+}
+
+// CFPTimeouts gets the timeouts engines are given
+// to respond to CFP commands
+func (c Config) CFPTimeouts() CFPTimeouts {
+	return CFPTimeouts{
+		Handshake: time.Duration(c.HandshakeTimeout),
+		Readyok:   time.Duration(c.ReadyokTimeout),
+		Bestmove:  time.Duration(c.BestmoveTimeout),
+	}
+}
+
+// UseDirectories sets where everything is found
+// and saved to the directories in the config
+func (c Config) UseDirectories() {
+	EngineDirectory = c.EngineDirectory
+	LogDirectory = c.LogDirectory
+	ProfileDirectory = c.ProfileDirectory
+	SessionFile = c.SessionFile
+}
@@ -0,0 +1,74 @@
+package connect4
+
+import (
+	"reflect"
+	"testing"
+)
+
+// won gets a position which player has won with the tiles
+func won(player int, tiles ...int) State {
+	s := NewState()
+	for _, i := range tiles {
+		s.Tiles[i] = player
+	}
+	s.Winner = player
+	return s
+}
+
+func TestLines(t *testing.T) {
+	// Every four in a row on the board, going right,
+	// down, down and to the right and down and to the left
+	expected := map[[4]int]bool{}
+	directions := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for row := 0; row < 6; row++ {
+		for column := 0; column < 7; column++ {
+			for _, d := range directions {
+				lastRow, lastColumn := row+3*d[0], column+3*d[1]
+				if lastRow >= 6 || lastColumn < 0 || lastColumn >= 7 {
+					continue
+				}
+				var line [4]int
+				for i := range line {
+					line[i] = 7*(row+i*d[0]) + column + i*d[1]
+				}
+				expected[line] = true
+			}
+		}
+	}
+	got := map[[4]int]bool{}
+	for _, line := range Lines() {
+		if !expected[line] {
+			t.Errorf("%v isn't four in a row", line)
+		}
+		if got[line] {
+			t.Errorf("%v is repeated", line)
+		}
+		got[line] = true
+	}
+	for line := range expected {
+		if !got[line] {
+			t.Errorf("%v is missing", line)
+		}
+	}
+}
+
+func TestWinningLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		state State
+		want  []int
+	}{
+		{"horizontal", won(Player1, 35, 36, 37, 38), []int{35, 36, 37, 38}},
+		{"vertical", won(Player2, 20, 27, 34, 41), []int{20, 27, 34, 41}},
+		{"positive diagonal", won(Player1, 1, 9, 17, 25), []int{1, 9, 17, 25}},
+		{"positive diagonal", won(Player2, 10, 18, 26, 34), []int{10, 18, 26, 34}},
+		{"negative diagonal", won(Player1, 3, 9, 15, 21), []int{3, 9, 15, 21}},
+		{"negative diagonal", won(Player2, 20, 26, 32, 38), []int{20, 26, 32, 38}},
+		{"no winner", NewState(), nil},
+	}
+	for _, test := range tests {
+		if got := test.state.WinningLine(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// WorkerDialTimeout is how long connecting to a worker can take
+	WorkerDialTimeout = 10 * time.Second
+	// WorkerReplyMargin is how much longer than the moves of a game
+	// can take that a worker is given to reply with its result. It
+	// covers loading the engines and them replying after being stopped
+	WorkerReplyMargin = time.Minute
+)
+
+// Coordinator plays the games of a match on workers rather than
+// with local engines. Each worker plays one game at a time and is
+// given the next game as soon as it's done. If a worker disconnects
+// or doesn't reply in time, the game is given to another worker
+// A game which a worker couldn't play ends the match, as it
+// would if the game had been played locally
+type Coordinator struct {
+	// Engine1 plays first in the first game and Engine2 plays
+	// second. They are paths RELATIVE to the workers' engine
+	// directories, so each worker needs to have both engines
+	Engine1 string
+	Engine2 string
+	// Workers are the addresses of the workers. An address
+	// can be given more than once to play several games on
+	// the same worker at the same time
+	Workers []string
+	// The rest are the same as for a Match
+	Games        int
+	TurnTime     time.Duration
+	TimeControl  *TimeControl
+	Openings     []State
+	Adjudication *Adjudication
+	Ponder       bool
+
+	lock sync.Mutex
+	// results are the games which have finished, in order
+	results []GameResult
+	running bool
+	err     error
+	// stop is closed when the match should stop
+	// after the games which are being played
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// resultChannel is where the result of each game is sent
+	resultChannel chan<- GameResult
+	// workerErrors is where the reason a worker was dropped is sent
+	workerErrors chan<- error
+}
+
+// NewCoordinator creates a coordinator for a match between
+// two engines which is played on workers
+func NewCoordinator(engine1, engine2 string, workers []string, games int, turnTime time.Duration) (*Coordinator, error) {
+	if engine1 == "" || engine2 == "" {
+		return nil, errors.New("match needs two engines")
+	}
+	if len(workers) == 0 {
+		return nil, errors.New("match needs at least one worker")
+	}
+	if games <= 0 {
+		return nil, errors.New("number of games must be positive")
+	}
+	if turnTime <= 0 {
+		return nil, errors.New("turn time must be positive")
+	}
+	return &Coordinator{
+		Engine1:  engine1,
+		Engine2:  engine2,
+		Workers:  workers,
+		Games:    games,
+		TurnTime: turnTime,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// NotifyResults sets the channel in which the result of each game
+// is sent. Results are sent in the order the games are in the match
+func (c *Coordinator) NotifyResults(channel chan<- GameResult) {
+	c.resultChannel = channel
+}
+
+// NotifyWorkerErrors sets the channel in which the reason
+// each worker stopped being used is sent
+func (c *Coordinator) NotifyWorkerErrors(channel chan<- error) {
+	c.workerErrors = channel
+}
+
+// Start starts playing the match in the background
+// The returned channel is closed once the match is over
+func (c *Coordinator) Start() (<-chan struct{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		return nil, errors.New("match is already being played")
+	}
+	c.running = true
+	done := make(chan struct{})
+	go func() {
+		err := c.play()
+		c.lock.Lock()
+		c.running = false
+		c.err = err
+		c.lock.Unlock()
+		close(done)
+	}()
+	return done, nil
+}
+
+// Stop stops the match once the games
+// which are being played have finished
+func (c *Coordinator) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Summary gets the progress of the match so far. The engines
+// are named by their paths until a game has finished
+func (c *Coordinator) Summary() MatchSummary {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	engine1, engine2 := c.Engine1, c.Engine2
+	if len(c.results) > 0 {
+		engine1, engine2 = c.results[0].Player1, c.results[0].Player2
+	}
+	return newMatchSummary(engine1, engine2, c.Games, c.results, c.running, c.err)
+}
+
+// workerReply is a reply from a worker along with
+// the worker and any error communicating with it
+type workerReply struct {
+	worker *workerConn
+	job    WorkerJob
+	reply  WorkerReply
+	err    error
+}
+
+// play hands out the games to the workers until they have all
+// been played or the match is stopped. An error is returned if
+// a game couldn't be played or there aren't any workers left
+// to play the remaining games
+func (c *Coordinator) play() error {
+	var idle []*workerConn
+	var lastErr error
+	for _, address := range c.Workers {
+		w, err := dialWorker(address)
+		if err != nil {
+			lastErr = err
+			c.workerError(err)
+			continue
+		}
+		defer w.conn.Close()
+		idle = append(idle, w)
+	}
+	queue := make([]int, c.Games)
+	for i := range queue {
+		queue[i] = i
+	}
+	// Games which finish out of order are held until
+	// all of the games before them have finished
+	finished := make(map[int]GameResult)
+	next := 0
+	replies := make(chan workerReply)
+	busy := 0
+	// failed is why a game couldn't be played. No more games
+	// are started once it's set and the match ends with it
+	// after the games which are being played have finished
+	var failed error
+	timeout := c.maxGameLength() + WorkerReplyMargin
+	for {
+		stopped := false
+		select {
+		case <-c.stop:
+			stopped = true
+		default:
+		}
+		for !stopped && failed == nil && len(idle) != 0 && len(queue) != 0 {
+			w, job := idle[0], c.job(queue[0])
+			idle, queue = idle[1:], queue[1:]
+			busy++
+			go func() {
+				reply, err := w.run(job, timeout)
+				replies <- workerReply{worker: w, job: job, reply: reply, err: err}
+			}()
+		}
+		if busy == 0 {
+			switch {
+			case failed != nil:
+				return failed
+			case stopped:
+				return errors.New("match was stopped")
+			case len(queue) == 0:
+				return nil
+			case lastErr != nil:
+				return errors.Wrap(lastErr, "no workers left to play the remaining games")
+			}
+			return errors.New("no workers left to play the remaining games")
+		}
+		r := <-replies
+		busy--
+		if r.err == nil && r.reply.Error != "" {
+			// The worker is fine but the game couldn't be played,
+			// so playing it on another worker wouldn't help
+			if failed == nil {
+				failed = errors.Errorf("couldn't finish game %d on worker %s: %s", r.job.Game+1, r.worker.address, r.reply.Error)
+			}
+			idle = append(idle, r.worker)
+			continue
+		}
+		if r.err == nil && r.reply.Result == nil {
+			r.err = errors.New("worker didn't send a result")
+		}
+		if r.err != nil {
+			// The game goes back to the front of the queue
+			// and the worker isn't given any more games
+			lastErr = errors.Wrapf(r.err, "worker %s couldn't play game %d", r.worker.address, r.job.Game+1)
+			c.workerError(lastErr)
+			r.worker.conn.Close()
+			queue = append([]int{r.job.Game}, queue...)
+			continue
+		}
+		idle = append(idle, r.worker)
+		finished[r.job.Game] = *r.reply.Result
+		for {
+			result, ok := finished[next]
+			if !ok {
+				break
+			}
+			delete(finished, next)
+			next++
+			c.lock.Lock()
+			c.results = append(c.results, result)
+			c.lock.Unlock()
+			if c.resultChannel != nil {
+				c.resultChannel <- result
+			}
+		}
+	}
+}
+
+// maxGameLength is the most time the moves of a game can take
+// Each player makes at most 21 of the 42 moves
+func (c *Coordinator) maxGameLength() time.Duration {
+	if c.TimeControl != nil {
+		return 2 * (c.TimeControl.Base + 21*c.TimeControl.Increment)
+	}
+	return 42 * c.TurnTime
+}
+
+// job creates the job for the game at index
+func (c *Coordinator) job(index int) WorkerJob {
+	result := WorkerJob{
+		Game:         index,
+		Engine1:      c.Engine1,
+		Engine2:      c.Engine2,
+		MoveTime:     Duration(c.TurnTime),
+		Opening:      matchOpening(c.Openings, index).CFPString(),
+		Adjudication: c.Adjudication,
+		Ponder:       c.Ponder,
+	}
+	// The engines swap sides every game
+	if index%2 == 1 {
+		result.Engine1, result.Engine2 = result.Engine2, result.Engine1
+	}
+	if c.TimeControl != nil {
+		result.TimeControl = c.TimeControl.String()
+	}
+	return result
+}
+
+// workerError sends the reason a worker was dropped
+func (c *Coordinator) workerError(err error) {
+	if c.workerErrors != nil {
+		c.workerErrors <- err
+	}
+}
+
+// workerConn is a coordinator's connection to a worker
+type workerConn struct {
+	address string
+	conn    net.Conn
+	scanner *bufio.Scanner
+	encoder *json.Encoder
+}
+
+// dialWorker connects to the worker at address
+func dialWorker(address string) (*workerConn, error) {
+	conn, err := net.DialTimeout("tcp", address, WorkerDialTimeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't connect to worker %s", address)
+	}
+	return &workerConn{
+		address: address,
+		conn:    conn,
+		scanner: bufio.NewScanner(conn),
+		encoder: json.NewEncoder(conn),
+	}, nil
+}
+
+// run sends a job to the worker and waits for its reply
+// An error is returned if it doesn't reply within timeout
+func (w *workerConn) run(job WorkerJob, timeout time.Duration) (WorkerReply, error) {
+	if err := w.encoder.Encode(job); err != nil {
+		return WorkerReply{}, errors.Wrap(err, "couldn't send job")
+	}
+	if err := w.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return WorkerReply{}, errors.Wrap(err, "couldn't set deadline for result")
+	}
+	if !w.scanner.Scan() {
+		if err := w.scanner.Err(); err != nil {
+			return WorkerReply{}, errors.Wrap(err, "couldn't receive result")
+		}
+		return WorkerReply{}, errors.New("worker disconnected")
+	}
+	var reply WorkerReply
+	if err := json.Unmarshal(w.scanner.Bytes(), &reply); err != nil {
+		return WorkerReply{}, errors.Wrap(err, "couldn't parse result")
+	}
+	if reply.Game != job.Game {
+		return WorkerReply{}, errors.Errorf("worker replied for game %d", reply.Game+1)
+	}
+	return reply, nil
+}
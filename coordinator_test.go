@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenWorker starts a worker on a loopback address
+// which serves coordinators until it's closed
+func listenWorker(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveCoordinator(conn, DefaultCFPTimeouts)
+		}
+	}()
+	return l
+}
+
+// listenDisconnectingWorker starts a worker on a loopback address
+// which disconnects part way through the first game it's sent
+// jobs is closed once it has been sent the game
+func listenDisconnectingWorker(t *testing.T) (net.Listener, <-chan struct{}) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := make(chan struct{})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if bufio.NewScanner(conn).Scan() {
+			close(jobs)
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+	return l, jobs
+}
+
+// playCoordinator plays a match on workers, returning its
+// summary and the reasons workers were dropped
+func playCoordinator(t *testing.T, engine1, engine2 string, listeners []net.Listener, games int) (MatchSummary, []error) {
+	workers := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		defer l.Close()
+		workers = append(workers, l.Addr().String())
+	}
+	c, err := NewCoordinator(engine1, engine2, workers, games, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workerErrors := make(chan error, len(workers))
+	c.NotifyWorkerErrors(workerErrors)
+	done, err := c.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("match didn't finish")
+	}
+	close(workerErrors)
+	var dropped []error
+	for err := range workerErrors {
+		dropped = append(dropped, err)
+	}
+	return c.Summary(), dropped
+}
+
+func TestCoordinatorReschedulesDisconnectedGames(t *testing.T) {
+	disconnecting, jobs := listenDisconnectingWorker(t)
+	workers := []net.Listener{listenWorker(t), disconnecting, listenWorker(t)}
+	summary, dropped := playCoordinator(t, "builtin:random", "builtin:greedy", workers, 6)
+	select {
+	case <-jobs:
+	default:
+		t.Fatal("disconnecting worker wasn't sent a game")
+	}
+	if summary.Error != "" {
+		t.Fatalf("match failed: %s", summary.Error)
+	}
+	if summary.Played != 6 || len(summary.Results) != 6 {
+		t.Errorf("played %d games with %d results, want 6", summary.Played, len(summary.Results))
+	}
+	if len(dropped) != 1 || !strings.Contains(dropped[0].Error(), disconnecting.Addr().String()) {
+		t.Errorf("got dropped workers %v, want only %s", dropped, disconnecting.Addr())
+	}
+}
+
+func TestCoordinatorFailsGamesWorkersCouldntPlay(t *testing.T) {
+	workers := []net.Listener{listenWorker(t), listenWorker(t)}
+	summary, dropped := playCoordinator(t, "builtin:random", "builtin:missing", workers, 4)
+	if !strings.Contains(summary.Error, "couldn't finish game") {
+		t.Errorf("got match error %q, want a game which couldn't be finished", summary.Error)
+	}
+	if len(dropped) != 0 {
+		t.Errorf("workers were dropped for a game which couldn't be played: %v", dropped)
+	}
+}
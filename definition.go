@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefinitionExtension is the file extension of engine definitions
+	// within EngineDirectory. Any other file is treated as an executable
+	DefinitionExtension = ".engine"
+)
+
+// EngineDefinition describes how an engine's process should be run
+// Definitions are JSON files within EngineDirectory which end
+// with DefinitionExtension
+type EngineDefinition struct {
+	// Source is the path RELATIVE to EngineDirectory
+	// that the definition was loaded from
+	Source string `json:"-"`
+	// Name is the name the engine is displayed with
+	// If it's empty, the name provided by the engine is used
+	Name string `json:"name,omitempty"`
+	// Path is the path to the engine's executable
+	// RELATIVE to EngineDirectory
+	Path string `json:"path,omitempty"`
+	// Address is the host and port of a remote engine which
+	// speaks CFP over TCP. It is used instead of Path, in which
+	// case Args, Dir, Env and Limits don't apply
+	Address string `json:"address,omitempty"`
+	// Builtin is the name of an engine written in Go which runs
+	// within Konnect4. It is used instead of Path, in which case
+	// Args, Dir, Env and Limits don't apply
+	Builtin string `json:"builtin,omitempty"`
+	// Args are the command-line arguments passed to the engine
+	Args []string `json:"args,omitempty"`
+	// Dir is the working directory of the engine RELATIVE to
+	// EngineDirectory. If it's empty, the working directory
+	// of Konnect4 is used
+	Dir string `json:"dir,omitempty"`
+	// Env are environment variables set for the engine in
+	// addition to the ones Konnect4 is running with
+	Env map[string]string `json:"env,omitempty"`
+	// Limits restricts the resources the engine's process can use
+	Limits ResourceLimits `json:"limits"`
+	// Timeouts override how long the engine is given to
+	// respond to commands
+	Timeouts EngineTimeouts `json:"timeouts"`
+}
+
+// LoadDefinition gets the definition of an engine from a path
+// RELATIVE to EngineDirectory. If the path is a definition file,
+// it's parsed. If it starts with BuiltinPrefix, it's a built-in
+// engine. Otherwise the path is an executable which is run
+// without any limits
+func LoadDefinition(path string) (EngineDefinition, error) {
+	if strings.HasPrefix(path, BuiltinPrefix) {
+		result := EngineDefinition{Source: path, Builtin: strings.TrimPrefix(path, BuiltinPrefix)}
+		return result, result.Validate()
+	}
+	if !strings.HasSuffix(path, DefinitionExtension) {
+		return EngineDefinition{Source: path, Path: path}, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(EngineDirectory, path))
+	if err != nil {
+		return EngineDefinition{}, errors.Wrap(err, "couldn't read engine definition")
+	}
+	result := EngineDefinition{Source: path}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return EngineDefinition{}, errors.Wrap(err, "couldn't parse engine definition")
+	}
+	if err := result.Validate(); err != nil {
+		return EngineDefinition{}, err
+	}
+	return result, nil
+}
+
+// Validate makes sure that the definition describes an engine
+// which can be run
+func (d EngineDefinition) Validate() error {
+	sources := 0
+	for _, source := range []string{d.Path, d.Address, d.Builtin} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("engine definition must have one of a path, an address or a builtin")
+	}
+	if d.Address != "" {
+		if _, _, err := net.SplitHostPort(d.Address); err != nil {
+			return errors.Wrap(err, "invalid address")
+		}
+	}
+	if d.Builtin != "" {
+		if _, ok := builtinEngines[d.Builtin]; !ok {
+			return errors.Errorf("there isn't a built-in engine called %s", d.Builtin)
+		}
+	}
+	if d.Path == "" && d.Limits.watched() {
+		return errors.New("resource limits can only be applied to engine executables")
+	}
+	if err := d.Limits.Validate(); err != nil {
+		return errors.Wrap(err, "invalid resource limits")
+	}
+	if err := d.Timeouts.Validate(); err != nil {
+		return errors.Wrap(err, "invalid timeouts")
+	}
+	return nil
+}
+
+// executable returns the path of the engine's executable and
+// makes sure that it exists. Remote and built-in
+// engines don't have one
+func (d EngineDefinition) executable() (string, error) {
+	if d.Path == "" {
+		return "", nil
+	}
+	path := filepath.Join(EngineDirectory, d.Path)
+	if _, err := os.Stat(path); err != nil {
+		return "", errors.Wrap(err, "couldn't find engine")
+	}
+	return path, nil
+}
+
+// transport creates the transport used to talk to the engine,
+// either a connection to its address, the built-in engine
+// or its process from path
+func (d EngineDefinition) transport(path string) (Transport, error) {
+	if d.Address != "" {
+		return newTCPTransport(d.Address), nil
+	}
+	if d.Builtin != "" {
+		return newInProcessTransport(builtinEngines[d.Builtin]()), nil
+	}
+	cmd, err := d.command(path)
+	if err != nil {
+		return nil, err
+	}
+	return processTransport{cmd}, nil
+}
+
+// command creates the process for an engine's executable
+// with its arguments, working directory and environment
+func (d EngineDefinition) command(path string) (*exec.Cmd, error) {
+	// The path is made absolute as it would otherwise be
+	// relative to the working directory of the engine
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get absolute engine path")
+	}
+	cmd := exec.Command(path, d.Args...)
+	if d.Dir != "" {
+		cmd.Dir = filepath.Join(EngineDirectory, d.Dir)
+		if info, err := os.Stat(cmd.Dir); err != nil || !info.IsDir() {
+			return nil, errors.New("couldn't find engine working directory")
+		}
+	}
+	if len(d.Env) != 0 {
+		// Sorting for a consistant order
+		keys := make([]string, 0, len(d.Env))
+		for k := range d.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		cmd.Env = os.Environ()
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+d.Env[k])
+		}
+	}
+	return cmd, nil
+}
@@ -2,10 +2,10 @@ package main
 
 import (
 	"fmt"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -31,24 +31,55 @@ type Develop struct {
 	game *Game
 	// server is used to serve the user with the frontend
 	server *Server
+	// log records everything that happens during the current game
+	// It's replaced for each new game, so logLock is held to use it
+	log     *GameLog
+	logLock sync.Mutex
+	// timeouts are how long engines are given to respond
+	// unless their definitions override them
+	timeouts CFPTimeouts
+	// outputs are the most recent messages sent to the
+	// output terminal, kept for the session
+	outputs    []OutputMessage
+	outputLock sync.Mutex
+	// results are the results of the most recent
+	// finished games, including those of matches
+	results     []GameResult
+	resultsLock sync.Mutex
+	// matches are the matches which have been started
+	matches     map[int]*Match
+	nextMatchID int
+	// saves asks for the session to be saved by the goroutine
+	// handling requests, which is the only one that uses the
+	// engines and players, after something else changes it
+	saves chan struct{}
 }
 
 // NewDevelop creates a new Develop struct which is
 // ready to serve the user with a frontend
-func NewDevelop() (*Develop, error) {
+func NewDevelop(config Config) (*Develop, error) {
 	// Creating a new server
-	s, err := NewServer("develop")
+	s, err := NewServer("develop", config.Address, config.MaxConnections, config.LegacyProtocol)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't make server")
 	}
+	// Starting a log for the first game
+	log, err := NewGameLog()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't make game log")
+	}
 	// Adding the result of the features to the result
 	return &Develop{
 		engines:         make(map[int]*Engine),
+		matches:         make(map[int]*Match),
 		nextEngineID:    0,
 		player1EngineID: -1,
 		player2EngineID: -1,
-		game:            NewGame(),
+		game:            NewGame(time.Duration(config.TurnTime)),
 		server:          s,
+		log:             log,
+		timeouts:        config.CFPTimeouts(),
+		saves:           make(chan struct{}, 1),
 	}, nil
 }
 
@@ -56,19 +87,33 @@ func NewDevelop() (*Develop, error) {
 // Start is not expected to exit unless the process is killed
 // or an error occurs, thus it always returns an error
 func (d *Develop) Start() error {
-	// Set up event listeners
+	// Set up event listeners. The previous session is restored
+	// before any requests are handled, while the server
+	// handles its events as engines take a while to load
 	go d.listenToClients()
 	go d.listenToGame()
 	// Start the server
 	return d.server.Start()
 }
 
+// restore restores the previous session or, if there
+// isn't one, loads the saved engine profiles
+func (d *Develop) restore() {
+	session, ok, err := LoadSession()
+	if err != nil {
+		d.output("ERROR", errors.Wrap(err, "couldn't load session").Error())
+	}
+	if ok {
+		d.restoreSession(session)
+	} else {
+		d.loadProfiles()
+	}
+	d.saveSession()
+}
+
 // listenToEngineInfo handles any info
 // events sent from an engine
-func (d *Develop) listenToEngineInfo(e *Engine) {
-	// Make channel to receive events
-	channel := make(chan string)
-	e.NotifyInfo(channel)
+func (d *Develop) listenToEngineInfo(id int, e *Engine, channel <-chan string) {
 	for {
 		// Get info from channel
 		info, ok := <-channel
@@ -76,34 +121,80 @@ func (d *Develop) listenToEngineInfo(e *Engine) {
 			return
 		}
 		// Output it to all clients
-		d.server.TriggerEvent(ServerEvent{
-			WSCommand: fmt.Sprintf(
-				"output time %s sender %s message %s",
-				FormatTime(time.Now()), e.Name, info,
-			),
-		})
+		d.output(e.LoadedName(), info)
+		// Lines of the search are shown in the analysis panel
+		if search, ok := ParseSearchInfo(strings.Fields(info)); ok && len(search.PV) != 0 {
+			d.server.TriggerEvent(ServerEvent{Message: NewAnalysisMessage(id, search)})
+		}
 	}
 }
 
 // listenToEngineComm handles any communications
 // between an engine and the gui
-func (d *Develop) listenToEngineComm(e *Engine) {
-	// Make channel to receive events
-	channel := make(chan Communication)
-	e.NotifyComm(channel)
+func (d *Develop) listenToEngineComm(e *Engine, channel <-chan Communication) {
 	for {
 		// Get communication from channel
 		comm, ok := <-channel
 		if !ok {
 			return
 		}
+		// Record it in the game log
+		name := e.LoadedName()
+		direction := "<--" + name
+		if comm.ToEngine {
+			direction = "-->" + name
+		}
+		d.writeLog(comm.Time, direction, strings.TrimSpace(comm.Message))
 		// Output it to all clients
-		d.server.TriggerEvent(ServerEvent{
-			WSCommand: fmt.Sprintf(
-				"communication time %s engine %s toengine %t message %s",
-				FormatTime(comm.Time), e.Name, comm.ToEngine, comm.Message,
-			),
-		})
+		d.server.TriggerEvent(ServerEvent{Message: CommunicationMessage{
+			Time: comm.Time, Engine: name,
+			ToEngine: comm.ToEngine, Message: comm.Message,
+		}})
+	}
+}
+
+// listenToEngineStderr handles anything an engine
+// writes to stderr
+func (d *Develop) listenToEngineStderr(e *Engine, channel <-chan StderrLine) {
+	for {
+		// Get line from channel
+		line, ok := <-channel
+		if !ok {
+			return
+		}
+		// Record it in the game log
+		name := e.LoadedName()
+		d.writeLog(line.Time, name+" stderr", line.Text)
+		// Output it to all clients
+		d.server.TriggerEvent(ServerEvent{Message: StderrMessage{
+			Time: line.Time, Engine: name, Message: line.Text,
+		}})
+	}
+}
+
+// listenToEngineExit handles an engine's process terminating
+// Clients are told when the engine crashes and whether it
+// has been restarted
+func (d *Develop) listenToEngineExit(id int, e *Engine, channel <-chan ExitStatus) {
+	for {
+		// Get exit status from channel
+		status, ok := <-channel
+		if !ok {
+			return
+		}
+		// The engine was told to quit, nothing else to do
+		if !status.Crashed {
+			return
+		}
+		// Output it to all clients
+		d.output("ERROR", fmt.Sprintf("Engine %s %s", e.LoadedName(), status))
+		d.server.TriggerEvent(ServerEvent{Message: EngineCrashesMessage{
+			ID: id, Count: e.Crashes(),
+		}})
+		// Without a restart, the engine won't exit again
+		if !status.Restarted {
+			return
+		}
 	}
 }
 
@@ -123,430 +214,569 @@ func (d *Develop) listenToGame() {
 		switch v := evt.(type) {
 		case GameOverEvent:
 			// If the game is over, tell each client
-			d.server.TriggerEvent(ServerEvent{
-				WSCommand: fmt.Sprintf("gameover winner %d", v.Winner),
-			})
-			// Send output command
-			d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-				"output time %s sender %s message %s",
-				FormatTime(time.Now()), "INFO", "Game has finished",
-			)})
+			d.gameOver(v)
 		case NewStateEvent:
 			// If there is a new position that has been reached,
 			// tell each of the clients
+			search := NewSearchMessage(v.Search)
 			d.server.TriggerEvent(ServerEvent{
-				WSCommand: fmt.Sprintf("position %s", v.State.CFPString()),
+				Message: PositionMessage{Position: v.State.CFPString(), Search: &search},
 			})
+		case EngineExitEvent:
+			// If a player's engine terminated, tell each client
+			// why the game has been adjudicated
+			d.output("ERROR", fmt.Sprintf(
+				"Player%d forfeits, engine %s", v.Player+1, v.Status,
+			))
+		case TimeForfeitEvent:
+			// If a player ran out of time, tell each client
+			d.output("ERROR", fmt.Sprintf(
+				"Player%d forfeits, ran out of time by %s", v.Player+1, v.Over,
+			))
+		case ForfeitEvent:
+			// If a player didn't reply with a legal move, tell each client
+			d.output("ERROR", fmt.Sprintf("Player%d forfeits, %s", v.Player+1, v.Reason))
 		case ErrorEvent:
 			// If there has been an error, tell each client
-			d.server.TriggerEvent(ServerEvent{
-				WSCommand: fmt.Sprintf(
-					"output time %s sender %s message %s",
-					FormatTime(time.Now()), "ERROR", v.Error.Error(),
-				),
-			})
+			d.output("ERROR", v.Error.Error())
 		}
+		// Keep the session up to date with the game
+		d.requestSave()
+	}
+}
+
+// requestSave asks for the session to be saved once the
+// request which is being handled, if any, is finished
+// A save which has already been asked for covers this one
+func (d *Develop) requestSave() {
+	select {
+	case d.saves <- struct{}{}:
+	default:
 	}
 }
 
-// listenToClients handles any incoming commands from
+// listenToClients handles any incoming requests from
 // any of the connected clients
 func (d *Develop) listenToClients() {
 	// Make channel to receive client events
 	channel := make(chan ClientEvent)
 	d.server.NotifyClientEvents(channel)
+	// Requests are only handled once the session has been restored
+	d.restore()
 	for {
-		// Get the event
-		evt, ok := <-channel
-		if !ok {
-			return
-		}
-		// Seperate the event command into its arguments
-		args := strings.Split(evt.WsCommand, " ")
-		// Exit early if there are no arguments
-		if len(args) == 0 {
+		// Get the event or save the session if it has changed
+		var evt ClientEvent
+		select {
+		case <-d.saves:
+			d.saveSession()
 			continue
+		case e, ok := <-channel:
+			if !ok {
+				return
+			}
+			evt = e
 		}
-		// Figure out which type of command has been received
-		// and execute the respective function
-		switch strings.ToLower(args[0]) {
-		case "init":
-			d.initRequest(evt)
-		case "newgame":
-			d.newGameRequest(evt)
-		case "setplayers":
-			d.setPlayersRequest(evt, args[1:])
-		case "play":
-			d.playRequest(evt)
-		case "pause":
-			d.pauseRequest(evt)
-		case "enginepaths":
-			d.enginePathsRequest(evt)
-		case "engine":
-			d.engineEventRequest(evt, args[1:])
-		case "options":
-			d.optionsRequest(evt, args[1:])
-		case "setoption":
-			d.setOptionRequest(evt, args[1:])
-		}
-	}
-}
-
-// initRequest handles any init commands sent from a client
-func (d *Develop) initRequest(evt ClientEvent) {
-	// Send engine load commands
-	for k, v := range d.engines {
-		d.server.Respond(evt, fmt.Sprintf(
-			"engine load id %d name %s author %s",
-			k, v.Name, v.Author,
-		))
-	}
-	// Send player set command
-	d.server.Respond(evt, fmt.Sprintf(
-		"players player1 %d player2 %d",
-		d.player1EngineID, d.player2EngineID,
-	))
-	// Send game history commands
-	d.server.Respond(evt, "newgame")
-	for i := 0; i <= d.game.HistoryIndex; i++ {
-		d.server.Respond(evt, "position "+d.game.History[i].CFPString())
-	}
-	if d.game.Running {
-		d.server.Respond(evt, "play")
-	}
-	if d.game.State.Winner != Empty {
-		d.server.Respond(evt, fmt.Sprintf("gameover winner %d", d.game.State.Winner))
+		// Execute the request, responding with any error
+		if err := d.handleRequest(evt); err != nil {
+			d.server.Respond(evt, ErrorMessage{Message: err.Error()})
+		}
+		// Keep the session up to date with any changes
+		d.saveSession()
 	}
-	// Send output command
-	d.server.Respond(evt, fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Connected successfully",
-	))
 }
 
-// newGameRequest handles any newgame commands sent from a client
-func (d *Develop) newGameRequest(evt ClientEvent) {
-	// Try to execute newgame
-	err := d.newGame()
-	// If there is an error, respond to the client
+// handleRequest figures out which type of request has been
+// received and executes the respective function. Requests
+// which don't have a response of their own are responded
+// to with an OkMessage once they have succeeded
+func (d *Develop) handleRequest(evt ClientEvent) error {
+	var err error
+	switch r := evt.Request.(type) {
+	case InitRequest:
+		d.initRequest(evt)
+	case NewGameRequest:
+		err = d.newGame()
+	case SetPlayersRequest:
+		err = errors.Wrap(d.setPlayers(r.Player1, r.Player2), "couldn't set players")
+	case PlayRequest:
+		err = errors.Wrap(d.play(), "couldn't play game")
+	case PauseRequest:
+		err = errors.Wrap(d.pause(), "couldn't pause game")
+	case EnginePathsRequest:
+		err = d.enginePathsRequest(evt)
+	case LoadEngineRequest:
+		var id int
+		if id, err = d.loadEngine(r.Path); err == nil {
+			err = d.engineRequest(evt, id)
+		}
+		err = errors.Wrap(err, "couldn't load engine")
+	case UnloadEngineRequest:
+		err = errors.Wrap(d.unloadEngine(r.ID), "couldn't unload engine")
+	case SetRestartRequest:
+		err = errors.Wrap(d.setRestart(r.ID, r.Enabled), "couldn't set restart policy")
+	case SetPonderRequest:
+		err = errors.Wrap(d.setPonder(r.ID, r.Enabled), "couldn't set pondering")
+	case SaveProfileRequest:
+		err = errors.Wrap(d.saveProfile(r.ID, r.Name), "couldn't save profile")
+	case OptionsRequest:
+		err = d.optionsRequest(evt, r.EngineID)
+	case SetOptionRequest:
+		err = errors.Wrap(d.setOptionRequest(r), "couldn't set option")
+	case EnginesRequest:
+		d.enginesRequest(evt)
+	case EngineRequest:
+		err = d.engineRequest(evt, r.ID)
+	case GameRequest:
+		d.gameRequest(evt)
+	case SetPositionRequest:
+		err = errors.Wrap(d.setPosition(r.Position), "couldn't set position")
+	case SetLimitsRequest:
+		err = errors.Wrap(d.setLimits(r.SearchLimits), "couldn't set search limits")
+	case ResultsRequest:
+		d.resultsRequest(evt)
+	case MatchesRequest:
+		d.matchesRequest(evt)
+	case MatchRequest:
+		err = d.matchRequest(evt, r.ID)
+	case StartMatchRequest:
+		err = errors.Wrap(d.startMatch(evt, r), "couldn't start match")
+	case StopMatchRequest:
+		err = errors.Wrap(d.stopMatch(r.ID), "couldn't stop match")
+	default:
+		err = errors.New("unsupported request")
+	}
 	if err != nil {
-		d.server.Respond(evt, fmt.Sprintf(
-			"output time %s sender %s message %s",
-			FormatTime(time.Now()), "ERROR", err.Error(),
-		))
+		return err
 	}
+	d.server.Respond(evt, OkMessage{})
+	return nil
 }
 
-// setPlayerRequest handles any setplayer commands sent from clients
-// args is a slice of arguments that follow setplayers in the command
-func (d *Develop) setPlayersRequest(evt ClientEvent, args []string) {
-	// Find the index of the string 'player1'
-	player1Index := SliceIndex(len(args), func(i int) bool {
-		return strings.ToLower(args[i]) == "player1"
-	})
-	// If it's not found, respond with an error
-	if player1Index == -1 {
-		d.respondError(evt, errors.New("couldn't find player1 in command string"))
-		return
-	}
-	// Find the index of the string 'player2'
-	player2Index := SliceIndex(len(args), func(i int) bool {
-		return strings.ToLower(args[i]) == "player2"
-	})
-	// If it's not found, respond with an error
-	if player2Index == -1 {
-		d.respondError(evt, errors.New("couldn't find player2 in command string"))
-		return
-	}
-	// Get the string values of the parameters
-	player1Str := strings.Join(args[player1Index+1:player2Index], " ")
-	player2Str := strings.Join(args[player2Index+1:len(args)], " ")
-	// Attempt to convert the player1 parameter to an integer
-	player1, err := strconv.Atoi(player1Str)
-	// If conversion fails, respond with an error
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't get player1"))
-		return
+// initRequest handles any init requests sent from a client
+func (d *Develop) initRequest(evt ClientEvent) {
+	// Send engine load messages
+	for k, v := range d.engines {
+		d.server.Respond(evt, EngineLoadMessage{ID: k, Name: v.Name, Author: v.Author})
+		d.server.Respond(evt, EngineCrashesMessage{ID: k, Count: v.Crashes()})
+		d.server.Respond(evt, EngineRestartMessage{ID: k, Enabled: v.RestartPolicy().Enabled})
+		d.server.Respond(evt, EnginePonderMessage{ID: k, Enabled: v.Ponders()})
 	}
-	// Attempt to convert the player2 parameter to an integer
-	player2, err := strconv.Atoi(player2Str)
-	// If conversion fails, respond with an error
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't get player2"))
-		return
+	// Send the recent stderr output of each engine
+	for _, v := range d.engines {
+		for _, line := range v.Stderr.Lines() {
+			d.server.Respond(evt, StderrMessage{
+				Time: line.Time, Engine: v.Name, Message: line.Text,
+			})
+		}
 	}
-	// Try to set the players
-	err = d.setPlayers(player1, player2)
-	// If an error occurs, respond with that error
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't set players"))
-		return
+	// Send players message
+	d.server.Respond(evt, PlayersMessage{
+		Player1: d.player1EngineID,
+		Player2: d.player2EngineID,
+	})
+	d.server.Respond(evt, LimitsMessage{SearchLimits: d.game.Limits})
+	// Send game history messages
+	d.server.Respond(evt, NewGameMessage{})
+	record := d.game.Record()
+	searches := searchMessages(record.Searches)
+	for i, state := range record.History {
+		message := PositionMessage{Position: state.CFPString()}
+		if i > 0 && i <= len(searches) {
+			message.Search = &searches[i-1]
+		}
+		d.server.Respond(evt, message)
 	}
-}
-
-// playRequest handles any play commands sent from clients
-func (d *Develop) playRequest(evt ClientEvent) {
-	// Try to play the game
-	err := d.play()
-	// If that fails, respond with an error
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't play game"))
+	if d.game.Running {
+		d.server.Respond(evt, PlayMessage{})
 	}
-}
-
-// pauseRequest handles any pause command sent from clients
-func (d *Develop) pauseRequest(evt ClientEvent) {
-	// Try to pause the game
-	err := d.pause()
-	// If that fails, respond with an error
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't pause game"))
+	if record.Outcome != nil {
+		d.server.Respond(evt, NewGameOverMessage(*record.Outcome))
+	}
+	// Send the recent output messages
+	d.outputLock.Lock()
+	for _, v := range d.outputs {
+		d.server.Respond(evt, v)
 	}
+	d.outputLock.Unlock()
+	// Send output message
+	d.server.Respond(evt, OutputMessage{
+		Time: time.Now(), Sender: "INFO", Message: "Connected successfully",
+	})
 }
 
-// enginePathsRequest handles and enginepaths commands sent from clients
-func (d *Develop) enginePathsRequest(evt ClientEvent) {
+// enginePathsRequest responds to an enginepaths request
+// with the paths of the engines that aren't loaded
+func (d *Develop) enginePathsRequest(evt ClientEvent) error {
 	// Get paths to all files within engine directory
 	files, err := FilesAt(EngineDirectory)
 	if err != nil {
-		d.server.Respond(evt, "noenginepaths")
-		d.respondError(evt, errors.Wrap(err, "couldn't get engine paths"))
-		return
+		d.server.Respond(evt, EnginePathsMessage{Paths: []string{}})
+		return errors.Wrap(err, "couldn't get engine paths")
 	}
+	files = append(files, builtinPaths()...)
 	// Remove any file paths to engines that are already loaded
 OUTER:
 	for i := len(files) - 1; i >= 0; i-- {
-		v := filepath.Join(EngineDirectory, files[i])
 		for _, e := range d.engines {
-			if e.Path == v {
+			if e.Definition.Source == files[i] {
 				files[i] = files[len(files)-1]
 				files = files[:len(files)-1]
 				continue OUTER
 			}
 		}
 	}
+	sort.Strings(files)
 	// Send response to client
-	if len(files) == 0 {
-		d.server.Respond(evt, "noenginepaths")
-		d.respondError(evt, errors.New("no engines in engines directory"))
-	} else {
-		d.server.Respond(evt, "enginepaths path "+strings.Join(files, " path "))
-	}
+	d.server.Respond(evt, EnginePathsMessage{Paths: files})
+	return nil
 }
 
-// engineEventRequest handles any engine operation commands sent from clients
-func (d *Develop) engineEventRequest(evt ClientEvent, args []string) {
-	// If there are no arguments, forget about it
-	if len(args) == 0 {
-		return
+// optionsRequest responds to an options request with
+// descriptions of the options avaliable for an engine
+func (d *Develop) optionsRequest(evt ClientEvent, engineID int) error {
+	engine, ok := d.engines[engineID]
+	if !ok {
+		return errors.New("no engine with that id")
 	}
-	// Figure out if this is a load or unload operation
-	switch strings.ToLower(args[0]) {
-	case "load":
-		d.engineLoadRequest(evt, args[1:])
-	case "unload":
-		d.engineUnloadRequest(evt, args[1:])
+	// Get an ordered list of the options for the engine
+	options := engine.Options()
+	result := OptionsMessage{
+		EngineID: engineID,
+		Options:  make([]OptionDescription, 0, len(options)),
 	}
+	for _, option := range options {
+		description, err := DescribeOption(option)
+		if err != nil {
+			continue
+		}
+		result.Options = append(result.Options, description)
+	}
+	sort.Slice(result.Options, func(i, j int) bool {
+		return result.Options[i].Name < result.Options[j].Name
+	})
+	// Send the options to the client
+	d.server.Respond(evt, result)
+	return nil
 }
 
-// engineLoadRequest handles any engine load command sent from clients
-func (d *Develop) engineLoadRequest(evt ClientEvent, args []string) {
-	// If there are no arguments, forget about it
-	if len(args) == 0 {
-		return
+// setOptionRequest handles a setoption request from a client
+// The new value is sent to the engine and every client
+func (d *Develop) setOptionRequest(r SetOptionRequest) error {
+	engine, ok := d.engines[r.EngineID]
+	if !ok {
+		return errors.New("no engine with that id")
 	}
-	// Find the index of the string 'path' in args
-	pathIndex := SliceIndex(len(args), func(i int) bool {
-		return args[i] == "path"
-	})
-	// If it isn't found, respond with an error
-	if pathIndex == -1 {
-		d.respondError(evt, errors.New("couldn't find path in command string"))
-		return
-	}
-	// Create path and attempt to load the engine
-	path := strings.Join(args[pathIndex+1:len(args)], " ")
-	err := d.loadEngine(path)
+	if d.inMatch(engine) {
+		return errors.New("engine is playing a match")
+	}
+	// Get the Option struct
+	option, ok := engine.Option(r.Name)
+	if !ok {
+		return errors.New("no option with that name")
+	}
+	// Set the option
+	value, err := d.setOption(engine, option, r.Value)
 	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't load engine"))
+		return err
+	}
+	if _, ok := option.(Button); !ok {
+		d.server.TriggerEvent(ServerEvent{Message: UpdateOptionMessage{
+			EngineID: r.EngineID, Name: r.Name, Value: value,
+		}})
 	}
+	return nil
 }
 
-// engineUnloadRequest handles any engine unload operations sent from clients
-func (d *Develop) engineUnloadRequest(evt ClientEvent, args []string) {
-	// If there are no arguments, forget about it
-	if len(args) == 0 {
-		return
+// describeEngine describes a loaded engine
+func (d *Develop) describeEngine(id int) (EngineDescription, error) {
+	engine, ok := d.engines[id]
+	if !ok {
+		return EngineDescription{}, errors.New("no engine with that id")
 	}
-	// Find the index of the string 'id' in args
-	idIndex := SliceIndex(len(args), func(i int) bool {
-		return args[i] == "id"
-	})
-	// If it isn't found, respond with an error
-	if idIndex == -1 {
-		d.respondError(evt, errors.New("couldn't find id in command string"))
-		return
-	}
-	// Try to convert the parameter into an integer
-	idString := strings.Join(args[idIndex+1:len(args)], " ")
-	id, err := strconv.Atoi(idString)
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't convert id into integer"))
-		return
+	return EngineDescription{
+		ID:      id,
+		Name:    engine.Name,
+		Author:  engine.Author,
+		Path:    engine.Definition.Source,
+		Crashes: engine.Crashes(),
+		Restart: engine.RestartPolicy().Enabled,
+		Ponder:  engine.Ponders(),
+	}, nil
+}
+
+// enginesRequest responds to an engines request with
+// a description of each of the loaded engines
+func (d *Develop) enginesRequest(evt ClientEvent) {
+	ids := make([]int, 0, len(d.engines))
+	for id := range d.engines {
+		ids = append(ids, id)
 	}
-	// Try to unload the engine with specified id
-	err = d.unloadEngine(id)
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't unload engine"))
+	sort.Ints(ids)
+	result := EnginesMessage{Engines: make([]EngineDescription, 0, len(ids))}
+	for _, id := range ids {
+		description, _ := d.describeEngine(id)
+		result.Engines = append(result.Engines, description)
 	}
+	d.server.Respond(evt, result)
 }
 
-// optionsRequest responds to an engineoptions command sent from a client
-// it gives the client commands which describe the options avaliable for an engine
-func (d *Develop) optionsRequest(evt ClientEvent, args []string) {
-	// Get the engine id
-	engineIDString := args[len(args)-1]
-	engineID, err := strconv.Atoi(engineIDString)
+// engineRequest responds to an engine request
+// with a description of the engine
+func (d *Develop) engineRequest(evt ClientEvent, id int) error {
+	description, err := d.describeEngine(id)
 	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't aquire engine id"))
-		return
+		return err
 	}
-	// Respond if there are no options
-	if len(d.engines[engineID].Options) == 0 {
-		d.server.Respond(evt, "nooptions")
-		return
+	d.server.Respond(evt, EngineMessage{EngineDescription: description})
+	return nil
+}
+
+// searchMessages gets how the players found each move of a game
+func searchMessages(searches []MoveSearch) []SearchMessage {
+	result := make([]SearchMessage, 0, len(searches))
+	for _, search := range searches {
+		result = append(result, NewSearchMessage(search))
 	}
-	// Get an ordered list of the options for the engine
-	options := d.engines[engineID].Options
-	ordered := make([]Option, 0, len(options))
-	for _, option := range options {
-		ordered = append(ordered, option)
+	return result
+}
+
+// gameRequest responds to a game request with the state of the game
+func (d *Develop) gameRequest(evt ClientEvent) {
+	record := d.game.Record()
+	result := GameMessage{
+		Player1:  d.player1EngineID,
+		Player2:  d.player2EngineID,
+		Position: record.History[len(record.History)-1].CFPString(),
+		History:  make([]string, 0, len(record.History)),
+		Running:  d.game.Running,
+		Winner:   record.Winner(),
+		TurnTime: Duration(d.game.TurnTime),
+		Limits:   d.game.Limits,
+		Searches: searchMessages(record.Searches),
 	}
-	sort.Slice(ordered, func(i, j int) bool {
-		return ordered[i].OptionName() < ordered[j].OptionName()
-	})
-	// For each option
-	for _, option := range ordered {
-		// Get the option string for the option
-		optionString, err := d.optionString(option)
-		if err != nil {
-			continue
-		}
-		// Send the option to the client
-		d.server.Respond(evt, fmt.Sprintf(
-			"option engineid %d name %s %s",
-			engineID, option.OptionName(), optionString,
-		))
+	for _, state := range record.History {
+		result.History = append(result.History, state.CFPString())
 	}
+	if record.Outcome != nil {
+		outcome := NewGameOverMessage(*record.Outcome)
+		result.Outcome = &outcome
+	}
+	d.server.Respond(evt, result)
 }
 
-// optionString returns a string which fully describes an Option
-func (d *Develop) optionString(o Option) (string, error) {
-	switch v := o.(type) {
-	case CheckBox:
-		return fmt.Sprintf(
-			"type check value %t",
-			v.Value,
-		), nil
-	case Spinner:
-		return fmt.Sprintf(
-			"type spin min %d max %d value %d",
-			v.Min, v.Max, v.Value,
-		), nil
-	case ComboBox:
-		vars := make([]string, 0, len(v.Vars))
-		for k := range v.Vars {
-			vars = append(vars, k)
+// gameOver tells each client that the game
+// has finished and records its result
+func (d *Develop) gameOver(outcome GameOverEvent) {
+	d.server.TriggerEvent(ServerEvent{Message: NewGameOverMessage(outcome)})
+	d.addResult(NewGameResult(d.game))
+	d.output("INFO", "Game has finished, "+outcome.Description())
+}
+
+// addResult records the result of a finished game
+func (d *Develop) addResult(result GameResult) {
+	d.resultsLock.Lock()
+	defer d.resultsLock.Unlock()
+	d.results = append(d.results, result)
+	if len(d.results) > ResultHistorySize {
+		d.results = d.results[len(d.results)-ResultHistorySize:]
+	}
+}
+
+// resultsRequest responds to a results request with
+// the results of the most recent finished games
+func (d *Develop) resultsRequest(evt ClientEvent) {
+	d.resultsLock.Lock()
+	result := ResultsMessage{Results: append([]GameResult{}, d.results...)}
+	d.resultsLock.Unlock()
+	d.server.Respond(evt, result)
+}
+
+// inMatch reports whether an engine is playing a match
+func (d *Develop) inMatch(e *Engine) bool {
+	if e == nil {
+		return false
+	}
+	for _, m := range d.matches {
+		if (m.Engine1 == e || m.Engine2 == e) && m.Running() {
+			return true
 		}
-		return fmt.Sprintf(
-			"type combo value %s var %s",
-			v.Value, strings.Join(vars, " var "),
-		), nil
-	case Button:
-		return "type button", nil
-	case String:
-		return fmt.Sprintf(
-			"type string value %s",
-			v.Value,
-		), nil
-	default:
-		return "", errors.New("option type not supported")
 	}
+	return false
 }
 
-// setOptionRequest handles a setoption command from a client
-// the engineid, option name and the value are parsed and send
-// sent to the engine
-func (d *Develop) setOptionRequest(evt ClientEvent, args []string) {
-	// Get the index of "engineid" in args
-	engineIDIndex := SliceIndex(len(args), func(i int) bool {
-		return args[i] == "engineid"
-	})
-	// Get the index of "name" in args
-	nameIndex := SliceIndex(len(args), func(i int) bool {
-		return args[i] == "name"
-	})
-	// Get the index of "value" in args
-	valueIndex := SliceIndex(len(args), func(i int) bool {
-		return args[i] == "value"
-	})
-	// Get the value
-	var value string
-	if valueIndex == -1 {
-		// If valueIndex is -1 (no "value" in args),
-		// valueIndex is set to the length of args
-		// as to not cause an error when aquiring name
-		valueIndex = len(args)
-	} else {
-		value = strings.Join(args[valueIndex+1:len(args)], " ")
+// startMatch starts a match between two loaded engines
+// and responds with a summary of the match
+func (d *Develop) startMatch(evt ClientEvent, r StartMatchRequest) error {
+	// The game and the match can't share engines
+	if d.game.Running {
+		return errors.New("cannot start match while game is being played")
+	}
+	engine1, ok := d.engines[r.Engine1]
+	if !ok {
+		return errors.New("no engine with that id")
+	}
+	engine2, ok := d.engines[r.Engine2]
+	if !ok {
+		return errors.New("no engine with that id")
+	}
+	if d.inMatch(engine1) || d.inMatch(engine2) {
+		return errors.New("engine is already playing a match")
 	}
-	// Get the engine id
-	engineIDString := strings.Join(args[engineIDIndex+1:nameIndex], " ")
-	engineID, err := strconv.Atoi(engineIDString)
+	turnTime := time.Duration(r.TurnTime)
+	if turnTime == 0 {
+		turnTime = d.game.TurnTime
+	}
+	match, err := NewMatch(engine1, engine2, r.Games, turnTime)
 	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "invalid engineid"))
-		return
+		return err
 	}
-	// Get the name
-	name := strings.Join(args[nameIndex+1:valueIndex], " ")
-	engine, ok := d.engines[engineID]
+	if r.Adjudication != nil {
+		if err := r.Adjudication.Validate(); err != nil {
+			return errors.Wrap(err, "invalid adjudication")
+		}
+		match.Adjudication = r.Adjudication
+	}
+	// The engines won't know the game's position after the match
+	d.game.Player1Status = -1
+	d.game.Player2Status = -1
+	// Start playing the match
+	results := make(chan GameResult)
+	match.NotifyResults(results)
+	done, err := match.Start()
+	if err != nil {
+		return err
+	}
+	id := d.nextMatchID
+	d.matches[id] = match
+	d.nextMatchID++
+	go d.listenToMatch(id, match, results, done)
+	d.output("INFO", fmt.Sprintf(
+		"Match %d started, %d games between %s and %s",
+		id, match.Games, engine1.Name, engine2.Name,
+	))
+	d.server.Respond(evt, MatchMessage{ID: id, MatchSummary: match.Summary()})
+	return nil
+}
+
+// listenToMatch handles the results of a match's
+// games until the match is over
+func (d *Develop) listenToMatch(id int, m *Match, results <-chan GameResult, done <-chan struct{}) {
+	var played []GameResult
+	for {
+		select {
+		case result := <-results:
+			d.addResult(result)
+			played = append(played, result)
+			wins1, wins2, draws := MatchScore(played)
+			d.output("INFO", fmt.Sprintf(
+				"Match %d game %d of %d finished, %s %d - %d %s with %d draws",
+				id, len(played), m.Games, m.Engine1.Name,
+				wins1, wins2, m.Engine2.Name, draws,
+			))
+		case <-done:
+			if summary := m.Summary(); summary.Error != "" {
+				d.output("ERROR", fmt.Sprintf("Match %d ended early, %s", id, summary.Error))
+			} else {
+				d.output("INFO", fmt.Sprintf("Match %d has finished", id))
+			}
+			return
+		}
+	}
+}
+
+// stopMatch stops a match after the game being played
+func (d *Develop) stopMatch(id int) error {
+	match, ok := d.matches[id]
 	if !ok {
-		d.respondError(evt, errors.New("no engine with that id"))
-		return
+		return errors.New("no match with that id")
 	}
-	// Get the Option struct
-	option, ok := engine.Options[name]
+	match.Stop()
+	return nil
+}
+
+// matchRequest responds to a match request with a summary of the match
+func (d *Develop) matchRequest(evt ClientEvent, id int) error {
+	match, ok := d.matches[id]
 	if !ok {
-		d.respondError(evt, errors.New("no option with that name"))
-		return
+		return errors.New("no match with that id")
 	}
-	// Set the option
-	value, err = d.setOption(engine, option, value)
-	if err != nil {
-		d.respondError(evt, errors.Wrap(err, "couldn't set option"))
-	} else if _, ok := option.(Button); !ok {
-		d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-			"updateoption engineid %d name %s value %s",
-			engineID, name, value,
-		)})
+	d.server.Respond(evt, MatchMessage{ID: id, MatchSummary: match.Summary()})
+	return nil
+}
+
+// matchesRequest responds to a matches request
+// with a summary of every match
+func (d *Develop) matchesRequest(evt ClientEvent) {
+	ids := make([]int, 0, len(d.matches))
+	for id := range d.matches {
+		ids = append(ids, id)
 	}
+	sort.Ints(ids)
+	result := MatchesMessage{Matches: make([]MatchMessage, 0, len(ids))}
+	for _, id := range ids {
+		result.Matches = append(result.Matches, MatchMessage{
+			ID: id, MatchSummary: d.matches[id].Summary(),
+		})
+	}
+	d.server.Respond(evt, result)
 }
 
 // newGame starts a new game
 func (d *Develop) newGame() error {
-	// Try to reset the game
-	err := d.game.Reset()
+	return d.startGame(NewState(), "Game has been reset")
+}
+
+// setPosition starts a new game from a position in CFP
+func (d *Develop) setPosition(position string) error {
+	state, err := StateFromCFP(position)
+	if err != nil {
+		return errors.Wrap(err, "couldn't parse position")
+	}
+	return d.startGame(state, "Position has been set")
+}
+
+// setLimits sets the limits of the players' searches
+// for the rest of the game and any later games
+func (d *Develop) setLimits(limits SearchLimits) error {
+	if err := d.game.SetLimits(limits); err != nil {
+		return err
+	}
+	// Tell the clients about the new limits
+	d.server.TriggerEvent(ServerEvent{Message: LimitsMessage{SearchLimits: limits}})
+	if limits.Limited() {
+		d.output("INFO", fmt.Sprintf("Searches are limited to %s", limits))
+	} else {
+		d.output("INFO", "Searches aren't limited")
+	}
+	return nil
+}
+
+// startGame starts a new game from a position
+// message is output once the game has started
+func (d *Develop) startGame(state State, message string) error {
+	// A game which was left unfinished is recorded as aborted
+	if outcome, ok := d.game.Abort(); ok {
+		d.gameOver(outcome)
+	}
+	// Try to set the game's position
+	err := d.game.Position(state)
 	if err != nil {
 		return errors.Wrap(err, "couldn't start new game")
 	}
+	// Start a new log for the new game
+	log, err := NewGameLog()
+	if err != nil {
+		return errors.Wrap(err, "couldn't make game log")
+	}
+	d.logLock.Lock()
+	d.log.Close()
+	d.log = log
+	d.logLock.Unlock()
 	// Send server events to all clients
-	d.server.TriggerEvent(ServerEvent{WSCommand: "newgame"})
-	d.server.TriggerEvent(ServerEvent{WSCommand: "position " + d.game.State.CFPString()})
+	d.server.TriggerEvent(ServerEvent{Message: NewGameMessage{}})
+	d.server.TriggerEvent(ServerEvent{Message: PositionMessage{Position: d.game.State.CFPString()}})
 	// Send output command
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Game has been reset",
-	)})
+	d.output("INFO", message)
 	return nil
 }
 
@@ -591,33 +821,30 @@ func (d *Develop) setPlayers(player1, player2 int) error {
 	}
 	// If this operation updated anything, send update to all clients
 	if engine1 != nil || engine2 != nil {
-		d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-			"players player1 %d player2 %d",
-			player1, player2,
-		)})
+		d.server.TriggerEvent(ServerEvent{Message: PlayersMessage{
+			Player1: player1, Player2: player2,
+		}})
 		// Send output command
-		d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-			"output time %s sender %s message %s",
-			FormatTime(time.Now()), "INFO", "New players have been set",
-		)})
+		d.output("INFO", "New players have been set")
 	}
 	return nil
 }
 
 // play starts the game playing
 func (d *Develop) play() error {
+	// The game's engines may be playing a match
+	if d.inMatch(d.game.Player1) || d.inMatch(d.game.Player2) {
+		return errors.New("cannot play game while its engines are playing a match")
+	}
 	// Attempt to set the game playing
 	err := d.game.Play()
 	if err != nil {
 		return errors.Wrap(err, "couldn't play game")
 	}
 	// Tell the clients that the game is going
-	d.server.TriggerEvent(ServerEvent{WSCommand: "play"})
+	d.server.TriggerEvent(ServerEvent{Message: PlayMessage{}})
 	// Send output command
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Started playing game",
-	)})
+	d.output("INFO", "Started playing game")
 	return nil
 }
 
@@ -629,49 +856,257 @@ func (d *Develop) pause() error {
 		return errors.Wrap(err, "couldn't pause game")
 	}
 	// Tell the clients that the game is paused
-	d.server.TriggerEvent(ServerEvent{WSCommand: "pause"})
+	d.server.TriggerEvent(ServerEvent{Message: PauseMessage{}})
 	// Send output command
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Paused game",
-	)})
+	d.output("INFO", "Paused game")
 	return nil
 }
 
 // loadEngine loads an engine with a specified path
+// The path is either to an executable or an engine definition
 // Note: the path is RELATIVE to the EngineDirectory in config.go
-func (d *Develop) loadEngine(path string) error {
+// The id of the loaded engine is returned
+func (d *Develop) loadEngine(path string) (int, error) {
+	// Try to get the engine's definition
+	definition, err := LoadDefinition(path)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't load engine definition")
+	}
+	return d.loadDefinition(definition)
+}
+
+// loadDefinition loads an engine described by a definition
+// The id of the loaded engine is returned
+func (d *Develop) loadDefinition(definition EngineDefinition) (int, error) {
 	// Try to create engine
-	engine, err := NewEngine(path, CFP)
+	protocol := NewCFP(definition.Timeouts.CFP(d.timeouts))
+	engine, err := NewEngine(definition, protocol)
 	if err != nil {
-		return errors.Wrap(err, "couldn't create engine")
+		return 0, errors.Wrap(err, "couldn't create engine")
 	}
-	// Set up engine event handlers
-	go d.listenToEngineInfo(engine)
-	go d.listenToEngineComm(engine)
+	// Set up engine event handlers before the engine is
+	// loaded so that its handshake is shown to the clients
+	info := make(chan string)
+	comm := make(chan Communication)
+	stderr := make(chan StderrLine)
+	exits := make(chan ExitStatus)
+	engine.NotifyInfo(info)
+	engine.NotifyComm(comm)
+	engine.NotifyStderr(stderr)
+	engine.NotifyExit(exits)
+	go d.listenToEngineInfo(d.nextEngineID, engine, info)
+	go d.listenToEngineComm(engine, comm)
+	go d.listenToEngineStderr(engine, stderr)
+	go d.listenToEngineExit(d.nextEngineID, engine, exits)
 	// Load the engine
 	err = engine.Load()
 	if err != nil {
-		return errors.Wrap(err, "couldn't start engine")
+		// Nothing is sent on the channels once they're unset
+		// so they can be closed to stop the event handlers
+		engine.NotifyInfo(nil)
+		engine.NotifyComm(nil)
+		engine.NotifyStderr(nil)
+		engine.NotifyExit(nil)
+		close(info)
+		close(comm)
+		close(stderr)
+		close(exits)
+		return 0, errors.Wrap(err, "couldn't start engine")
 	}
 	// Store the engine in the loaded engines map
-	d.engines[d.nextEngineID] = engine
+	id := d.nextEngineID
+	d.engines[id] = engine
+	d.nextEngineID++
 	// Tell clients that engine is loaded
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"engine load id %d name %s author %s",
-		d.nextEngineID, engine.Name, engine.Author,
-	)})
+	d.server.TriggerEvent(ServerEvent{Message: EngineLoadMessage{
+		ID: id, Name: engine.Name, Author: engine.Author,
+	}})
 	// Send output command
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Engine loaded successfully",
-	)})
-	d.nextEngineID++
+	d.output("INFO", "Engine loaded successfully")
+	return id, nil
+}
+
+// loadProfiles loads an engine for each of the saved profiles
+// Profiles which fail to load are reported and skipped
+func (d *Develop) loadProfiles() {
+	profiles, failures := LoadProfiles()
+	for _, err := range failures {
+		d.output("ERROR", err.Error())
+	}
+	for _, profile := range profiles {
+		if _, err := d.loadProfile(profile); err != nil {
+			d.output("ERROR", errors.Wrapf(err, "couldn't load profile %s", profile.Name).Error())
+		}
+	}
+}
+
+// loadProfile loads the engine described by a profile and
+// sets its options to the values saved in the profile
+// The id of the loaded engine is returned
+func (d *Develop) loadProfile(profile EngineProfile) (int, error) {
+	id, err := d.loadDefinition(profile.Engine)
+	if err != nil {
+		return 0, err
+	}
+	engine := d.engines[id]
+	// Set the options that have been saved
+	for name, value := range profile.Options {
+		option, ok := engine.Option(name)
+		if !ok {
+			d.output("ERROR", fmt.Sprintf(
+				"Profile %s has option %s which the engine doesn't specify",
+				profile.Name, name,
+			))
+			continue
+		}
+		if _, err := d.setOption(engine, option, value); err != nil {
+			return id, errors.Wrapf(err, "couldn't set option %s", name)
+		}
+	}
+	// Restore the restart policy
+	engine.SetRestart(profile.Restart)
+	d.server.TriggerEvent(ServerEvent{Message: EngineRestartMessage{
+		ID: id, Enabled: profile.Restart.Enabled,
+	}})
+	// Restore pondering
+	engine.SetPonder(profile.Ponder)
+	d.server.TriggerEvent(ServerEvent{Message: EnginePonderMessage{
+		ID: id, Enabled: engine.Ponders(),
+	}})
+	return id, nil
+}
+
+// saveProfile saves the current configuration of a loaded
+// engine, including its options, as a named profile
+func (d *Develop) saveProfile(id int, name string) error {
+	engine, ok := d.engines[id]
+	if !ok {
+		return errors.New("no engine with that id")
+	}
+	if d.inMatch(engine) {
+		return errors.New("engine is playing a match")
+	}
+	if err := NewProfile(name, engine).Save(); err != nil {
+		return errors.Wrap(err, "couldn't save profile")
+	}
+	d.output("INFO", fmt.Sprintf("Saved profile %s", name))
 	return nil
 }
 
+// session takes a snapshot of the engines, players and game
+// It is only used by the goroutine which handles requests, as
+// that is the only one which changes the engines and players
+func (d *Develop) session() Session {
+	record := d.game.Record()
+	result := Session{
+		Time:         time.Now(),
+		Engines:      make([]SessionEngine, 0, len(d.engines)),
+		NextEngineID: d.nextEngineID,
+		Player1:      d.player1EngineID,
+		Player2:      d.player2EngineID,
+		History:      make([]string, 0, len(record.History)),
+		Winner:       record.Winner(),
+		TurnTime:     Duration(d.game.TurnTime),
+		Limits:       d.game.Limits,
+		Searches:     searchMessages(record.Searches),
+	}
+	if record.Outcome != nil {
+		result.Reason = record.Outcome.Reason
+	}
+	for id, engine := range d.engines {
+		result.Engines = append(result.Engines, SessionEngine{
+			ID:      id,
+			Profile: NewProfile(engine.Name, engine),
+			Debug:   engine.Debugging(),
+		})
+	}
+	sort.Slice(result.Engines, func(i, j int) bool {
+		return result.Engines[i].ID < result.Engines[j].ID
+	})
+	for _, state := range record.History {
+		result.History = append(result.History, state.CFPString())
+	}
+	d.outputLock.Lock()
+	result.Output = append([]OutputMessage{}, d.outputs...)
+	d.outputLock.Unlock()
+	return result
+}
+
+// saveSession writes a snapshot of the session to SessionFile
+func (d *Develop) saveSession() {
+	if err := d.session().Save(); err != nil {
+		d.output("ERROR", errors.Wrap(err, "couldn't save session").Error())
+	}
+}
+
+// restoreSession reloads the engines of a saved session with
+// the same ids and options, then puts the players and the
+// game back to how they were
+func (d *Develop) restoreSession(s Session) {
+	// Put back the messages from the previous session
+	d.outputLock.Lock()
+	d.outputs = append(s.Output, d.outputs...)
+	d.outputLock.Unlock()
+	// Reload the engines
+	for _, engine := range s.Engines {
+		d.nextEngineID = engine.ID
+		id, err := d.loadProfile(engine.Profile)
+		if err != nil {
+			d.output("ERROR", errors.Wrapf(err, "couldn't restore engine %s", engine.Profile.Name).Error())
+			continue
+		}
+		if engine.Debug {
+			if err := d.engines[id].Debug(true); err != nil {
+				d.output("ERROR", errors.Wrap(err, "couldn't enable debug mode").Error())
+			}
+		}
+	}
+	if s.NextEngineID > d.nextEngineID {
+		d.nextEngineID = s.NextEngineID
+	}
+	// Restore the game
+	history := make([]State, 0, len(s.History))
+	for _, position := range s.History {
+		state, err := StateFromCFP(position)
+		if err != nil {
+			d.output("ERROR", errors.Wrap(err, "couldn't restore game").Error())
+			history = nil
+			break
+		}
+		history = append(history, state)
+	}
+	if history != nil {
+		searches := make([]MoveSearch, 0, len(s.Searches))
+		for _, search := range s.Searches {
+			searches = append(searches, search.MoveSearch())
+		}
+		if err := d.game.Restore(history, searches, s.Winner, s.Reason); err != nil {
+			d.output("ERROR", errors.Wrap(err, "couldn't restore game").Error())
+		}
+	}
+	if s.TurnTime > 0 {
+		d.game.SetTimeout(time.Duration(s.TurnTime))
+	}
+	if err := d.game.SetLimits(s.Limits); err != nil {
+		d.output("ERROR", errors.Wrap(err, "couldn't restore search limits").Error())
+	}
+	// Restore the players. Each is set on its own so that
+	// one missing engine doesn't stop the other being set
+	if err := d.setPlayers(s.Player1, -1); err != nil {
+		d.output("ERROR", errors.Wrap(err, "couldn't restore player1").Error())
+	}
+	if err := d.setPlayers(-1, s.Player2); err != nil {
+		d.output("ERROR", errors.Wrap(err, "couldn't restore player2").Error())
+	}
+	d.output("INFO", fmt.Sprintf("Restored session from %s", FormatTime(s.Time)))
+}
+
 // unloadEngine unloads a loaded engine with a specified id
 func (d *Develop) unloadEngine(id int) error {
+	// Engines can't be unloaded while they play a match
+	if engine, ok := d.engines[id]; ok && d.inMatch(engine) {
+		return errors.New("engine is playing a match")
+	}
 	// If the engine is player1, set player1 to nil
 	if d.player1EngineID == id {
 		err := d.game.SetPlayer1(nil)
@@ -696,17 +1131,52 @@ func (d *Develop) unloadEngine(id int) error {
 	if err != nil {
 		return errors.Wrap(err, "couldn't make engine quit")
 	}
+	if engine.LastExit().Killed {
+		d.output("ERROR", fmt.Sprintf(
+			"Engine %s was killed, %s timeout of %s fired before it quit",
+			engine.Name, TimeoutQuit, engine.QuitTimeout(),
+		))
+	}
 	// Delete the engine from the loaded engines map
 	delete(d.engines, id)
 	// Tell the clients the engine has been unloaded
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"engine unload id %d", id,
-	)})
+	d.server.TriggerEvent(ServerEvent{Message: EngineUnloadMessage{ID: id}})
 	// Send output command
-	d.server.TriggerEvent(ServerEvent{WSCommand: fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "INFO", "Engine has been disconnected",
-	)})
+	d.output("INFO", "Engine has been disconnected")
+	return nil
+}
+
+// setRestart enables or disables restarting an engine after it crashes
+func (d *Develop) setRestart(id int, enable bool) error {
+	engine, ok := d.engines[id]
+	if !ok {
+		return errors.New("no engine with that id")
+	}
+	if d.inMatch(engine) {
+		return errors.New("engine is playing a match")
+	}
+	policy := engine.RestartPolicy()
+	policy.Enabled = enable
+	engine.SetRestart(policy)
+	// Tell the clients about the new policy
+	d.server.TriggerEvent(ServerEvent{Message: EngineRestartMessage{
+		ID: id, Enabled: enable,
+	}})
+	return nil
+}
+
+// setPonder enables or disables an engine pondering in games
+// The change is used from the engine's next turn
+func (d *Develop) setPonder(id int, enable bool) error {
+	engine, ok := d.engines[id]
+	if !ok {
+		return errors.New("no engine with that id")
+	}
+	engine.SetPonder(enable)
+	// Tell the clients about the change
+	d.server.TriggerEvent(ServerEvent{Message: EnginePonderMessage{
+		ID: id, Enabled: enable,
+	}})
 	return nil
 }
 
@@ -777,10 +1247,27 @@ func (d *Develop) setOption(engine *Engine, option Option, value string) (string
 	return outValue, nil
 }
 
-// respondError responds to a client event with an error
-func (d *Develop) respondError(evt ClientEvent, err error) {
-	d.server.Respond(evt, fmt.Sprintf(
-		"output time %s sender %s message %s",
-		FormatTime(time.Now()), "ERROR", err.Error(),
-	))
+// writeLog records a message in the current game's log
+func (d *Develop) writeLog(t time.Time, sender, message string) {
+	d.logLock.Lock()
+	defer d.logLock.Unlock()
+	d.log.Write(t, sender, message)
+}
+
+// output sends a message to the output terminal of
+// every client and records it in the game log
+func (d *Develop) output(sender, message string) {
+	now := time.Now()
+	d.writeLog(now, sender, message)
+	d.outputLock.Lock()
+	d.outputs = append(d.outputs, OutputMessage{
+		Time: now, Sender: sender, Message: message,
+	})
+	if len(d.outputs) > OutputHistorySize {
+		d.outputs = d.outputs[len(d.outputs)-OutputHistorySize:]
+	}
+	d.outputLock.Unlock()
+	d.server.TriggerEvent(ServerEvent{Message: OutputMessage{
+		Time: now, Sender: sender, Message: message,
+	}})
 }
@@ -2,11 +2,15 @@
 
 // Protocol objects
 let socket;                 // The websocket connection to the GUI
+let requestID = 0;          // The id of the last request sent to the backend
 let state;                  // The current state of the GUI
 
 // GUI object
 let gui;
 
+// The version of the protocol spoken with the backend
+const PROTOCOL_VERSION = 1;
+
 // Constants for graphics
 const BORDER_STYLE  = "#ffffff";
 const BORDER_WIDTH  = 1;
@@ -16,6 +20,22 @@ const X_COLOUR      = "#ff0000";
 const O_RADIUS      = 20;
 const O_WIDTH       = 5;
 const O_COLOUR      = "#0000ff";
+const LINE_WIDTH    = 8;
+const LINE_COLOUR   = "#ffff00";
+
+// Constants for the analysis panel
+const COLUMNS           = 7;
+const EVAL_BAR_SCALE    = 400;  // Scores this far from zero fill most of the bar
+
+// Constants for the game chart
+const CHART_WIDTH       = 400;
+const CHART_HEIGHT      = 300;
+const CHART_PADDING     = 20;
+const CHART_LABEL       = "#8f93a2";
+const CHART_AXIS        = "#505672";
+const CHART_CURRENT     = "#80cbc4";
+const CHART_LINE_WIDTH  = 2;
+const CHART_POINT       = 3;
 
 // Constants for state
 const EMPTY     = 0;
@@ -41,14 +61,18 @@ const NEXT_BUTTON                   = 6;
 const END_BUTTON                    = 7;
 const ENGINE_LIST_GO_BACK_BUTTON    = 8;
 const SETTINGS_GO_BACK_BUTTON       = 9;
+const SAVE_PROFILE_BUTTON           = 10;
+const SEARCH_LIMITS_BUTTON          = 11;
 
 // Constants for engine specific controls
-const ENGINE_BUTTONS_START      = 10;
-const ENGINE_BUTTONS_STRIDE     = 4;
+const ENGINE_BUTTONS_START      = 12;
+const ENGINE_BUTTONS_STRIDE     = 6;
 const ENGINE_PLAYER1_BUTTON     = 0;
 const ENGINE_PLAYER2_BUTTON     = 1;
 const ENGINE_SETTINGS_BUTTON    = 2;
 const ENGINE_DISCONNECT_BUTTON  = 3;
+const ENGINE_RESTART_BUTTON     = 4;
+const ENGINE_PONDER_BUTTON      = 5;
 
 // GUI State
 class State {
@@ -60,10 +84,16 @@ class State {
         this.playing        = false;
     
         this.history        = [];
+        this.searches       = [];
         this.gameOver       = false;
         this.winner         = null;
+        this.winningLine    = null;
+        this.limits         = {};
 
         this.historyIndex   = 0;
+
+        this.analysisEngine = -1;
+        this.analysis       = new Array(COLUMNS).fill(null);
     }
 
     loadEngine(engine) {
@@ -78,6 +108,24 @@ class State {
             this.player2ID = -1;
     }
 
+    updateCrashes(engineID, crashes) {
+        let engine = this.engines["engine"+engineID];
+        if (engine != undefined)
+            engine.crashes = crashes;
+    }
+
+    updateRestart(engineID, enabled) {
+        let engine = this.engines["engine"+engineID];
+        if (engine != undefined)
+            engine.restart = enabled;
+    }
+
+    updatePonder(engineID, enabled) {
+        let engine = this.engines["engine"+engineID];
+        if (engine != undefined)
+            engine.ponder = enabled;
+    }
+
     updatePlayers(engineID1, engineID2) {
         this.player1ID = engineID1;
         this.player2ID = engineID2;
@@ -85,20 +133,45 @@ class State {
 
     newGame() {
         this.history        = [];
+        this.searches       = [];
         this.historyIndex   = 0;
         this.gameOver       = false;
         this.winner         = null;
+        this.winningLine    = null;
+        this.clearAnalysis();
     }
 
-    updatePosition(position) {
+    // updatePosition adds a position to the history along with
+    // the search for the move which reached it, if there was one
+    updatePosition(position, search) {
         this.history.push(position);
+        this.searches.push(search || null);
         this.historyIndex = this.history.length - 1;
+        this.clearAnalysis();
+    }
+
+    // updateAnalysis keeps the latest line about each column
+    // from the engine which is searching
+    updateAnalysis(line) {
+        if (line.pv.length == 0 || line.pv[0] < 0 || line.pv[0] >= COLUMNS)
+            return;
+        if (line.id != this.analysisEngine) {
+            this.clearAnalysis();
+            this.analysisEngine = line.id;
+        }
+        this.analysis[line.pv[0]] = line;
     }
 
-    setGameOver(winner) {
-        this.playing    = false;
-        this.gameOver   = true;
-        this.winner     = winner;
+    clearAnalysis() {
+        this.analysisEngine = -1;
+        this.analysis       = new Array(COLUMNS).fill(null);
+    }
+
+    setGameOver(winner, line) {
+        this.playing        = false;
+        this.gameOver       = true;
+        this.winner         = winner;
+        this.winningLine    = line || null;
     }
 
     play() {
@@ -156,6 +229,12 @@ function buttonClick() {
     case SETTINGS_GO_BACK_BUTTON:
         gui.hideSettingsOverlay();
         break;
+    case SAVE_PROFILE_BUTTON:
+        requestSaveProfile(gui.settingsEngineID);
+        break;
+    case SEARCH_LIMITS_BUTTON:
+        requestSearchLimits();
+        break;
     }
     if (this.buttonId >= ENGINE_BUTTONS_START) {
         // If we reach this point, it's en engine specific button
@@ -175,6 +254,7 @@ class GUI {
         // Space to store the engine and settings DOM elements
         this.engines                = {};
         this.settings               = {};
+        this.settingsEngineID       = -1;
 
         // Getting DOM elements
         this.loadOverlay            = document.getElementById("load-engine-overlay");
@@ -188,9 +268,11 @@ class GUI {
         this.settingsList           = document.getElementById("engine-settings");
         this.settingsOverlayLoader  = document.getElementsByClassName("loader")[1];
         this.settingsGoBackButton   = document.getElementsByClassName("go-back-button")[1];
+        this.saveProfileButton      = document.getElementsByClassName("save-profile-button")[0];
 
         this.newGameButton          = document.getElementById("new-game");
         this.setupBoardButton       = document.getElementById("setup-board");
+        this.searchLimitsButton     = document.getElementById("search-limits");
         
         this.canvas                 = document.getElementById("game-screen-canvas");
 
@@ -202,6 +284,17 @@ class GUI {
         
         this.outputTerminal         = document.getElementById("output-terminal").getElementsByTagName("p")[0];
         this.communicationTerminal  = document.getElementById("communications-terminal").getElementsByTagName("p")[0];
+        this.stderrTerminal         = document.getElementById("stderr-terminal").getElementsByTagName("p")[0];
+
+        this.analysisEngine         = document.getElementById("analysis-engine");
+        this.analysisLines          = document.getElementById("analysis-lines");
+        this.createAnalysisLines();
+
+        this.chart                  = document.getElementById("game-chart");
+        this.chart.width            = CHART_WIDTH;
+        this.chart.height           = CHART_HEIGHT;
+        this.chartCtx               = this.chart.getContext("2d");
+        this.chart.addEventListener("click", chartClick, false);
 
         // Getting canvas drawing context
         this.canvas.width   = 700;
@@ -216,6 +309,7 @@ class GUI {
         this.loadEngineButton.buttonId          = LOAD_ENGINE_BUTTON;
         this.newGameButton.buttonId             = NEW_GAME_BUTTON;
         this.setupBoardButton.buttonId          = SETUP_BOARD_BUTTON;
+        this.searchLimitsButton.buttonId        = SEARCH_LIMITS_BUTTON;
         this.startButton.buttonId               = START_BUTTON;
         this.previousButton.buttonId            = PREVIOUS_BUTTON;
         this.playPauseButton.buttonId           = PLAY_PAUSE_BUTTON;
@@ -223,10 +317,12 @@ class GUI {
         this.endButton.buttonId                 = END_BUTTON;
         this.engineListGoBackButton.buttonId    = ENGINE_LIST_GO_BACK_BUTTON;
         this.settingsGoBackButton.buttonId      = SETTINGS_GO_BACK_BUTTON;
+        this.saveProfileButton.buttonId         = SAVE_PROFILE_BUTTON;
 
         this.loadEngineButton.addEventListener("click", buttonClick, false);
         this.newGameButton.addEventListener("click", buttonClick, false);
         this.setupBoardButton.addEventListener("click", buttonClick, false);
+        this.searchLimitsButton.addEventListener("click", buttonClick, false);
         this.startButton.addEventListener("click", buttonClick, false);
         this.previousButton.addEventListener("click", buttonClick, false);
         this.playPauseButton.addEventListener("click", buttonClick, false);
@@ -234,6 +330,7 @@ class GUI {
         this.endButton.addEventListener("click", buttonClick, false);
         this.engineListGoBackButton.addEventListener("click", buttonClick, false);
         this.settingsGoBackButton.addEventListener("click", buttonClick, false);
+        this.saveProfileButton.addEventListener("click", buttonClick, false);
     }
 
     showLoadOverlay() {
@@ -275,7 +372,8 @@ class GUI {
         this.loadOverlay.style.display = "none";
     }
 
-    showSettingsOverlay() {
+    showSettingsOverlay(engineID) {
+        this.settingsEngineID = engineID;
         let settings = this.settingsList.getElementsByClassName("engine-setting");
         for (let i = settings.length-1; i >= 0; i--) {
             let setting = settings[i];
@@ -283,12 +381,14 @@ class GUI {
             delete this.settings["engineid " + setting.engineID + " name " + setting.name];
         }
         this.settingsGoBackButton.style.display     = "none";
+        this.saveProfileButton.style.display        = "none";
         this.settingsOverlayLoader.style.display    = "block";
         this.settingsOverlay.style.display          = "flex";
     }
 
     hideSettingsLoader() {
         this.settingsGoBackButton.style.display     = "block";
+        this.saveProfileButton.style.display        = "block";
         this.settingsOverlayLoader.style.display    = "none"
     }
 
@@ -335,8 +435,11 @@ class GUI {
         let engineAuthor = document.createElement("p");
         engineAuthor.classList.add("engine-author");
         engineAuthor.innerHTML = "by " + engine.author;
+        let engineCrashes = document.createElement("p");
+        engineCrashes.classList.add("engine-crashes");
         center.appendChild(engineName);
         center.appendChild(engineAuthor);
+        center.appendChild(engineCrashes);
         engineInfo.appendChild(center);
         let player1 = document.createElement("div");
         player1.classList.add("engine-player1");
@@ -358,14 +461,109 @@ class GUI {
         disconnect.innerHTML = "<h3>DC</h3>";
         disconnect.buttonId = index + ENGINE_DISCONNECT_BUTTON;
         disconnect.addEventListener("click", buttonClick, false);
+        let restart = document.createElement("div");
+        restart.classList.add("engine-restart");
+        restart.innerHTML = "<h3>AR</h3>";
+        restart.buttonId = index + ENGINE_RESTART_BUTTON;
+        restart.addEventListener("click", buttonClick, false);
+        let ponder = document.createElement("div");
+        ponder.classList.add("engine-ponder");
+        ponder.innerHTML = "<h3>PO</h3>";
+        ponder.buttonId = index + ENGINE_PONDER_BUTTON;
+        ponder.addEventListener("click", buttonClick, false);
         this.engines["engine"+engine.id].appendChild(engineInfo);
         this.engines["engine"+engine.id].appendChild(player1);
         this.engines["engine"+engine.id].appendChild(player2);
         this.engines["engine"+engine.id].appendChild(settings);
+        this.engines["engine"+engine.id].appendChild(restart);
+        this.engines["engine"+engine.id].appendChild(ponder);
         this.engines["engine"+engine.id].appendChild(disconnect);
         this.engineList.insertBefore(this.engines["engine"+engine.id], this.loadEngineButton); 
     }
 
+    // createAnalysisLines adds a line to the analysis panel for each column
+    // showing the column, an evaluation bar, the score and the line of play
+    createAnalysisLines() {
+        for (let column = 0; column < COLUMNS; column++) {
+            let line = document.createElement("li");
+            line.classList.add("analysis-line");
+            let name = document.createElement("span");
+            name.classList.add("analysis-column");
+            name.innerHTML = column;
+            let bar = document.createElement("div");
+            bar.classList.add("eval-bar");
+            let fill = document.createElement("div");
+            fill.classList.add("eval-bar-fill");
+            bar.appendChild(fill);
+            let score = document.createElement("span");
+            score.classList.add("analysis-score");
+            let pv = document.createElement("span");
+            pv.classList.add("analysis-pv");
+            line.appendChild(name);
+            line.appendChild(bar);
+            line.appendChild(score);
+            line.appendChild(pv);
+            this.analysisLines.appendChild(line);
+        }
+    }
+
+    updateAnalysis() {
+        let engine = state.engines["engine"+state.analysisEngine];
+        this.analysisEngine.innerHTML = engine == undefined ? "" : engine.name;
+        let lines = this.analysisLines.getElementsByClassName("analysis-line");
+        for (let column = 0; column < COLUMNS; column++) {
+            let analysis = state.analysis[column];
+            let fill = lines[column].getElementsByClassName("eval-bar-fill")[0];
+            let score = lines[column].getElementsByClassName("analysis-score")[0];
+            let pv = lines[column].getElementsByClassName("analysis-pv")[0];
+            lines[column].classList.toggle("best", analysis != null && analysis.multipv == 1);
+            if (analysis == null || !analysis.hasscore) {
+                fill.style.width = "0%";
+                score.innerHTML = "";
+                pv.innerHTML = analysis == null ? "" : "d" + analysis.depth + " " + analysis.pv.join(" ");
+                continue;
+            }
+            fill.style.width = evalBarWidth(analysis) + "%";
+            score.innerHTML = formatScore(analysis);
+            pv.innerHTML = "d" + analysis.depth + " " + analysis.pv.join(" ");
+        }
+    }
+
+    updateCrashes(engineID) {
+        let engine = state.engines["engine"+engineID];
+        if (this.engines["engine"+engineID] == null || engine == undefined) return;
+        let crashes = this.engines["engine"+engineID].getElementsByClassName("engine-crashes")[0];
+        if (engine.crashes == 0) {
+            crashes.innerHTML = "";
+        } else if (engine.crashes == 1) {
+            crashes.innerHTML = "crashed once";
+        } else {
+            crashes.innerHTML = "crashed " + engine.crashes + " times";
+        }
+    }
+
+    updateRestart(engineID) {
+        let engine = state.engines["engine"+engineID];
+        if (this.engines["engine"+engineID] == null || engine == undefined) return;
+        let restart = this.engines["engine"+engineID].getElementsByClassName("engine-restart")[0];
+        if (engine.restart) {
+            restart.classList.add("active");
+        } else {
+            restart.classList.remove("active");
+        }
+    }
+
+    updatePonder(engineID) {
+        let engine = state.engines["engine"+engineID];
+        if (this.engines["engine"+engineID] == null || engine == undefined) return;
+        let ponder = this.engines["engine"+engineID].getElementsByClassName("engine-ponder")[0];
+        if (engine.ponder) {
+            ponder.classList.add("active");
+        } else {
+            ponder.classList.remove("active");
+        }
+    }
+
     unloadEngine(engineID) {
         if (this.engines["engine"+engineID] == null) return;
         this.engines["engine"+engineID].remove();
@@ -397,6 +595,12 @@ class GUI {
         // Setup Board Button
         // For now just keeping it disabled
         this.setupBoardButton.classList.add("disabled");
+        // Search Limits Button
+        if (state.playing) {
+            this.searchLimitsButton.classList.add("disabled");
+        } else {
+            this.searchLimitsButton.classList.remove("disabled");
+        }
         // Start Button and Previous Button
         if (state.playing || state.historyIndex == 0) {
             this.startButton.classList.add("disabled");
@@ -467,6 +671,86 @@ class GUI {
             else
                 this.drawO(xcenter, ycenter);
         }
+        // Highlighting the four connected tiles at the end of the game
+        if (state.winningLine != null && state.historyIndex == state.history.length - 1)
+            this.drawLine(state.winningLine);
+    }
+
+    // drawChart plots the evaluation, search depth and time of each
+    // player's moves across the game, one above the other
+    drawChart() {
+        let ctx = this.chartCtx;
+        ctx.clearRect(0, 0, this.chart.width, this.chart.height);
+        let moves = state.searches.length - 1;
+        let height = this.chart.height / 3;
+        let evals = [], depths = [], times = [];
+        for (let i = 1; i <= moves; i++) {
+            let search = state.searches[i];
+            if (search == null) continue;
+            let player = state.history[i-1].player;
+            if (search.hasscore) evals.push({move: i, player: player, value: Math.tanh(search.score / EVAL_BAR_SCALE)});
+            if (search.depth > 0) depths.push({move: i, player: player, value: search.depth});
+            times.push({move: i, player: player, value: parseDuration(search.time)});
+        }
+        this.drawChartSeries("Evaluation", 0, height, moves, evals, -1, 1);
+        this.drawChartSeries("Depth", height, height, moves, depths, 0, Math.max(1, ...depths.map(p => p.value)));
+        this.drawChartSeries("Time", 2 * height, height, moves, times, 0, Math.max(0.001, ...times.map(p => p.value)));
+        // Marking the position being viewed
+        if (moves > 0) {
+            let x = chartX(state.historyIndex, moves, this.chart.width);
+            ctx.strokeStyle = CHART_CURRENT;
+            ctx.lineWidth   = 1;
+            ctx.beginPath();
+            ctx.moveTo(x, 0);
+            ctx.lineTo(x, this.chart.height);
+            ctx.stroke();
+        }
+    }
+
+    // drawChartSeries plots points within a band of the chart starting at top,
+    // with each player's points joined up in their own colour
+    drawChartSeries(title, top, height, moves, points, min, max) {
+        let ctx = this.chartCtx;
+        let y = value => top + height - CHART_PADDING / 2 - (value - min) / (max - min) * (height - CHART_PADDING * 1.5);
+        ctx.fillStyle = CHART_LABEL;
+        ctx.font = "10px Arial";
+        ctx.fillText(title, 2, top + 10);
+        ctx.strokeStyle = CHART_AXIS;
+        ctx.lineWidth   = 1;
+        ctx.beginPath();
+        ctx.moveTo(CHART_PADDING, y(Math.max(min, 0)));
+        ctx.lineTo(this.chart.width - CHART_PADDING, y(Math.max(min, 0)));
+        ctx.stroke();
+        for (let player of [PLAYER_1, PLAYER_2]) {
+            let colour = player == PLAYER_1 ? X_COLOUR : O_COLOUR;
+            let own = points.filter(p => p.player == player);
+            ctx.strokeStyle = colour;
+            ctx.fillStyle   = colour;
+            ctx.lineWidth   = CHART_LINE_WIDTH;
+            ctx.beginPath();
+            own.forEach((p, i) => {
+                let x = chartX(p.move, moves, this.chart.width);
+                if (i == 0) ctx.moveTo(x, y(p.value));
+                else ctx.lineTo(x, y(p.value));
+            });
+            ctx.stroke();
+            for (let p of own) {
+                ctx.beginPath();
+                ctx.arc(chartX(p.move, moves, this.chart.width), y(p.value), CHART_POINT, 0, Math.PI * 2);
+                ctx.fill();
+            }
+        }
+    }
+
+    drawLine(line) {
+        let first   = line[0];
+        let last    = line[line.length - 1];
+        this.ctx.strokeStyle    = LINE_COLOUR;
+        this.ctx.lineWidth      = LINE_WIDTH;
+        this.ctx.beginPath();
+        this.ctx.moveTo((first % 7 + 0.5) * this.canvas.width / 7, (Math.floor(first / 7) + 0.5) * this.canvas.height / 6);
+        this.ctx.lineTo((last % 7 + 0.5) * this.canvas.width / 7, (Math.floor(last / 7) + 0.5) * this.canvas.height / 6);
+        this.ctx.stroke();
     }
 
     drawX(xcenter, ycenter) {
@@ -504,6 +788,13 @@ class GUI {
         this.communicationTerminal.innerHTML += "["+time+"]["+prefix+"]: " + message;
         this.communicationTerminal.scrollTo(0, this.communicationTerminal.scrollHeight);
     }
+
+    stderr(time, engine, message) {
+        if (this.stderrTerminal.innerHTML != "")
+            this.stderrTerminal.innerHTML += "<br>";
+        this.stderrTerminal.innerHTML += "["+time+"]["+engine+"]: " + message;
+        this.stderrTerminal.scrollTo(0, this.stderrTerminal.scrollHeight);
+    }
 }
 
 class Setting {
@@ -692,14 +983,12 @@ class Setting {
         this.DOM = result;
     }
 
-    wsSetOptionCommand() {
-        if (this.type == SETTING_BUTTON) {
-            return "setoption engineid "+this.engineID+
-                " name "+this.name;
-        } else {
-            return "setoption engineid "+this.engineID+
-                " name "+this.name+" value "+this.requestValue;
+    setOptionPayload() {
+        let payload = {engineid: this.engineID, name: this.name};
+        if (this.type != SETTING_BUTTON) {
+            payload.value = String(this.requestValue);
         }
+        return payload;
     }
 }
 
@@ -735,61 +1024,60 @@ class Position {
 }
 
 class Engine {
-    constructor(engineString) {
-        let args = engineString.split(" ");
-        let idIndex = args.indexOf("id");
-        let nameIndex = args.indexOf("name");
-        let authorIndex = args.indexOf("author");
-        this.id = args.slice(idIndex+1, nameIndex).join(" ");
-        this.id = parseInt(this.id);
-        this.name = args.slice(nameIndex+1, authorIndex).join(" ");
-        this.author = args.slice(authorIndex+1, args.length).join(" ");
+    constructor(payload) {
+        this.id = payload.id;
+        this.name = payload.name;
+        this.author = payload.author;
+        this.crashes = 0;
+        this.restart = false;
+        this.ponder = false;
     }
 }
 
+// command handles a message from the backend. Every message
+// has a type and a payload, the contents of which depend on the type
 function command(msg) {
-    let args = msg.split(" ");
-    switch (args.shift()) {
+    let envelope = JSON.parse(msg);
+    let payload = envelope.payload || {};
+    switch (envelope.type) {
+    case "error":
+        error(payload);
+        break;
     case "enginepaths":
-        showPaths(args);
+        showPaths(payload);
         break;
-    case "noenginepaths":
-        gui.hideLoadOverlay();
+    case "engineload":
+        loadEngine(payload);
         break;
-    case "engines":
-        switch (args.shift()) {
-        case "load":
-            loadEngines(args);
-            break;
-        case "unload":
-            unloadEngines(args);
-            break;
-        }
+    case "engineunload":
+        unloadEngine(payload);
         break;
-    case "engine":
-        switch (args.shift()) {
-        case "load":
-            loadEngine(args);
-            break;
-        case "unload":
-            unloadEngine(args);
-            break;
-        }
+    case "enginecrashes":
+        engineCrashes(payload);
+        break;
+    case "enginerestart":
+        engineRestart(payload);
+        break;
+    case "engineponder":
+        enginePonder(payload);
         break;
     case "players":
-        players(args);
+        players(payload);
+        break;
+    case "analysis":
+        analysis(payload);
+        break;
+    case "limits":
+        limits(payload);
         break;
     case "newgame":
         newGame();
         break;
     case "position":
-        position(args);
+        position(payload);
         break;
     case "gameover":
-        gameOver(args);
-        break;
-    case "history":
-        history(args);
+        gameOver(payload);
         break;
     case "play":
         play();
@@ -797,105 +1085,147 @@ function command(msg) {
     case "pause":
         pause();
         break;
-    case "option":
-        option(args);
-        break;
-    case "nooptions":
-        gui.hideSettingsOverlay();
+    case "options":
+        options(payload);
         break;
     case "updateoption":
-        updateOption(args);
+        updateOption(payload);
         break;
     case "output":
-        output(args);
+        output(payload);
         break;
     case "communication":
-        communication(args);
+        communication(payload);
+        break;
+    case "stderr":
+        stderr(payload);
         break;
     }
     gui.updateButtons();
 }
 
-function showPaths(args) {
-    gui.hidePathLoader();
-    let lastPathIndex = null;
-    for (let i = 0; i < args.length; i++) {
-        if (args[i] == "path" && lastPathIndex != null) {
-            gui.showFilepath(args.slice(lastPathIndex+1, i).join(" "));
-        }
-        if (args[i] == "path") {
-            lastPathIndex = i;
-        }
-    }
-    gui.showFilepath(args.slice(lastPathIndex+1, args.length).join(" "));
+// formatTime formats a timestamp the same way as the backend's logs
+function formatTime(timestamp) {
+    let t = new Date(timestamp);
+    let pad = (n, width) => String(n).padStart(width, "0");
+    return pad(t.getHours(), 2)+":"+pad(t.getMinutes(), 2)+":"+pad(t.getSeconds(), 2)+" "+
+        pad(t.getDate(), 2)+"/"+pad(t.getMonth()+1, 2)+"/"+pad(t.getFullYear(), 4);
 }
 
-function loadEngines(args) {
-    let lastEngineIndex = null;
-    for (let i = 0; i < args.length; i++) {
-        if (args[i] == "engine" && lastEngineIndex != null) {
-            loadEngine(args.slice(lastEngineIndex+1, i));
-        }
-        if (args[i] == "engine") {
-            lastEngineIndex = i;
-        }
-    }
-    loadEngine(args.slice(lastEngineIndex+1, args.length));
+function error(payload) {
+    // Whatever was being loaded isn't coming
+    gui.hidePathLoader();
+    gui.hideSettingsLoader();
+    gui.output(formatTime(Date.now()), "ERROR", payload.message);
 }
 
-function unloadEngines(args) {
-    let lastEngineIndex = null;
-    for (let i = 0; i < args.length; i++) {
-        if (args[i] == "engine" || lastEngineIndex != null) {
-            unloadEngine(args.slice(lastEngineIndex+1, i));
-        }
-        if (args[i] == "engine") {
-            lastEngineIndex = i;
-        }
+function showPaths(payload) {
+    if (payload.paths.length == 0) {
+        gui.hideLoadOverlay();
+        return;
+    }
+    gui.hidePathLoader();
+    for (let i = 0; i < payload.paths.length; i++) {
+        gui.showFilepath(payload.paths[i]);
     }
-    unloadEngine(args.slice(lastEngineIndex+1, args.length));
 }
 
-function loadEngine(args) {
-    let engine = new Engine(args.join(" "));
+function loadEngine(payload) {
+    let engine = new Engine(payload);
     state.loadEngine(engine);
     gui.loadEngine(engine);
 }
 
-function unloadEngine(args) {
-    let id = parseInt(args[args.length-1]);
-    state.unloadEngine(id);
-    gui.unloadEngine(id);
+function unloadEngine(payload) {
+    state.unloadEngine(payload.id);
+    gui.unloadEngine(payload.id);
+}
+
+function engineCrashes(payload) {
+    state.updateCrashes(payload.id, payload.count);
+    gui.updateCrashes(payload.id);
+}
+
+function engineRestart(payload) {
+    state.updateRestart(payload.id, payload.enabled);
+    gui.updateRestart(payload.id);
+}
+
+function enginePonder(payload) {
+    state.updatePonder(payload.id, payload.enabled);
+    gui.updatePonder(payload.id);
+}
+
+function players(payload) {
+    state.updatePlayers(payload.player1, payload.player2);
+    gui.updatePlayers(payload.player1, payload.player2);
 }
 
-function players(args) {
-    let player1Index = args.indexOf("player1");
-    let player2Index = args.indexOf("player2");
-    let player1 = parseInt(args.slice(player1Index+1, player2Index).join(" "));
-    let player2 = parseInt(args.slice(player2Index+1, args.length).join(" "));
-    state.updatePlayers(player1, player2);
-    gui.updatePlayers(player1, player2);
+function limits(payload) {
+    state.limits = payload;
 }
 
 function newGame() {
     state.newGame();
+    gui.updateAnalysis();
 }
 
-function position(args) {
-    let position = new Position(args[args.length - 1]);
-    state.updatePosition(position);
+function position(payload) {
+    state.updatePosition(new Position(payload.position), payload.search);
+    gui.updateAnalysis();
 }
 
-function gameOver(args) {
-    state.setGameOver(parseInt(args[args.length-1]));
+function analysis(payload) {
+    state.updateAnalysis(payload);
+    gui.updateAnalysis();
 }
 
-function history(args) {
-    newGame();
-    for (let i = 0; i < args.length; i++)
-        if (args[i] != "position")
-            position(args[i]);
-}   
+// chartX is where a move is plotted across the game chart
+function chartX(move, moves, width) {
+    if (moves == 0) return CHART_PADDING;
+    return CHART_PADDING + move / moves * (width - 2 * CHART_PADDING);
+}
+
+// chartClick shows the position reached by the move nearest to
+// where the game chart was clicked, unless the game is being played
+function chartClick(evt) {
+    let moves = state.searches.length - 1;
+    if (state.playing || moves <= 0) return;
+    let rect = gui.chart.getBoundingClientRect();
+    let x = (evt.clientX - rect.left) * gui.chart.width / rect.width;
+    let move = Math.round((x - CHART_PADDING) / (gui.chart.width - 2 * CHART_PADDING) * moves);
+    state.historyIndex = Math.min(moves, Math.max(0, move));
+    gui.updateButtons();
+}
+
+// parseDuration converts a duration written like 1m30.5s or 250ms to seconds
+function parseDuration(text) {
+    const units = {h: 3600, m: 60, s: 1, ms: 0.001, "us": 0.000001, "µs": 0.000001, ns: 0.000000001};
+    let seconds = 0;
+    let re = /([0-9.]+)(h|ms|m|s|us|µs|ns)/g;
+    let match;
+    while ((match = re.exec(text || "")) != null)
+        seconds += parseFloat(match[1]) * units[match[2]];
+    return seconds;
+}
+
+// evalBarWidth is how full a line's evaluation bar is as a percentage
+// Half full is an even position for the player to move
+function evalBarWidth(line) {
+    if (line.mate > 0) return 100;
+    if (line.mate < 0) return 0;
+    return 50 + 50 * Math.tanh(line.score / EVAL_BAR_SCALE);
+}
+
+// formatScore writes a line's score, with forced wins written as #moves
+function formatScore(line) {
+    if (line.mate != undefined && line.mate != 0) return "#" + line.mate;
+    return (line.score > 0 ? "+" : "") + line.score;
+}
+
+function gameOver(payload) {
+    state.setGameOver(payload.winner, payload.line);
+}
 
 function play() {
     state.play();
@@ -905,137 +1235,101 @@ function pause() {
     state.pause();
 }
 
-function option(args) {
+function options(payload) {
+    if (payload.options.length == 0) {
+        gui.hideSettingsOverlay();
+        return;
+    }
     gui.hideSettingsLoader();
+    for (let i = 0; i < payload.options.length; i++) {
+        option(payload.engineid, payload.options[i]);
+    }
+}
 
-    let engineIdIndex   = args.indexOf("engineid");
-    let nameIndex       = args.indexOf("name");
-    let typeIndex       = args.indexOf("type");
-
-    let engineId    = parseInt(args[engineIdIndex+1]);
-    let name        = args.slice(nameIndex+1, typeIndex).join(" ");
-    let type        = args[typeIndex+1];
-
-    switch (type) {
+function option(engineId, o) {
+    switch (o.type) {
     case "check":
-        checkboxOption(engineId, name, args.slice(typeIndex+2, args.length));
+        gui.showSetting(new Setting(engineId, o.name, SETTING_CHECK, o.value, null, null, null));
         break;
     case "spin":
-        spinnerOption(engineId, name, args.slice(typeIndex+2, args.length));
+        gui.showSetting(new Setting(engineId, o.name, SETTING_SPIN, o.value || 0, o.min || 0, o.max || 0, null));
         break;
     case "combo":
-        comboboxOption(engineId, name, args.slice(typeIndex+2, args.length));
+        if (!o.vars || o.vars.length == 0) return;
+        gui.showSetting(new Setting(engineId, o.name, SETTING_COMBO, o.value, null, null, o.vars));
         break;
     case "button":
-        buttonOption(engineId, name);
+        gui.showSetting(new Setting(engineId, o.name, SETTING_BUTTON, null, null, null, null));
         break;
     case "string":
-        stringOption(engineId, name, args.slice(typeIndex+2, args.length));
+        gui.showSetting(new Setting(engineId, o.name, SETTING_STRING, o.value || "", null, null, null));
         break;
     }
 }
 
-function checkboxOption(engineId, name, args) {
-    let valueIndex = args.indexOf("value");
-    let value = args[valueIndex+1];
-    if (value == "true") {
-        gui.showSetting(new Setting(engineId, name, SETTING_CHECK, true, null, null, null));
-    } else {
-        gui.showSetting(new Setting(engineId, name, SETTING_CHECK, false, null, null, null));
-    }
-}
-
-function spinnerOption(engineId, name, args) {
-    let minIndex    = args.indexOf("min");
-    let maxIndex    = args.indexOf("max");
-    let valueIndex  = args.indexOf("value");
-    
-    let min     = parseInt(args[minIndex+1]);
-    let max     = parseInt(args[maxIndex+1]);
-    let value   = parseInt(args[valueIndex+1]);
-
-    gui.showSetting(new Setting(engineId, name, SETTING_SPIN, value, min, max, null));
-}
-
-function comboboxOption(engineId, name, args) {
-    let valueIndex = args.indexOf("value");
-    let varIndexes = getAllIndexes(args, "var");
-    
-    if (varIndexes.length == 0) {
-        return
-    }
-    
-    let value   = args.slice(valueIndex+1, varIndexes[0]).join(" ");
-    let vars    = [];
-    
-    varIndexes.push(args.length);
-    for (let i = 0; i < varIndexes.length-1; i++) {
-        vars.push(args.slice(varIndexes[i]+1, varIndexes[i+1]).join(" "));
-    }
-
-    gui.showSetting(new Setting(engineId, name, SETTING_COMBO, value, null, null, vars));
+function updateOption(payload) {
+    gui.updateSetting(payload.engineid, payload.name, payload.value);
 }
 
-function buttonOption(engineId, name) {
-    gui.showSetting(new Setting(engineId, name, SETTING_BUTTON, null, null, null, null));
+function output(payload) {
+    gui.output(formatTime(payload.time), payload.sender, payload.message);
 }
 
-function stringOption(engineId, name, args) {
-    let valueIndex = args.indexOf("value");
-    let value = args.slice(valueIndex+1, args.length).join(" ");
-
-    gui.showSetting(new Setting(engineId, name, SETTING_STRING, value, null, null, null));
+function communication(payload) {
+    gui.communication(formatTime(payload.time), payload.engine, payload.toengine, payload.message);
 }
 
-function updateOption(args) {
-    let engineIdIndex   = args.indexOf("engineid");
-    let nameIndex       = args.indexOf("name");
-    let valueIndex      = args.indexOf("value");
-
-    let engineId    = parseInt(args[engineIdIndex+1]);
-    let name        = args.slice(nameIndex+1, valueIndex).join(" ");
-    let value       = args.slice(valueIndex+1, args.length).join(" ");
-
-    gui.updateSetting(engineId, name, value);
+function stderr(payload) {
+    gui.stderr(formatTime(payload.time), payload.engine, payload.message);
 }
 
-function output(args) {
-    let timeIndex       = args.indexOf("time");
-    let senderIndex     = args.indexOf("sender");
-    let messageIndex    = args.indexOf("message");
-    
-    let time    = args.slice(timeIndex+1, senderIndex).join(" ");
-    let sender  = args.slice(senderIndex+1, messageIndex).join(" ");
-    let message = args.slice(messageIndex+1, args.length).join(" ");
-
-    gui.output(time, sender, message);
+// send sends a request to the backend. Each request is given
+// an id which the backend uses in its response
+function send(type, payload) {
+    requestID++;
+    let envelope = {v: PROTOCOL_VERSION, id: requestID, type: type};
+    if (payload) envelope.payload = payload;
+    socket.send(JSON.stringify(envelope));
 }
 
-function communication(args) {
-    let timeIndex       = args.indexOf("time");
-    let engineIndex     = args.indexOf("engine");
-    let toengineIndex   = args.indexOf("toengine");
-    let messageIndex    = args.indexOf("message");
-
-    let time        = args.slice(timeIndex+1, engineIndex).join(" ");
-    let engine      = args.slice(engineIndex+1, toengineIndex).join(" ");
-    let toengine    = args.slice(toengineIndex+1, messageIndex).join(" ");
-    let message     = args.slice(messageIndex+1, args.length).join(" ");
-
-    toengine = toengine.toLowerCase() == "true";
-    gui.communication(time, engine, toengine, message);
+function requestNewGame() {
+    send("newgame");
 }
 
-function requestNewGame() {
-    socket.send("newgame");
+// requestSearchLimits asks for the limits of the players' searches,
+// written like the arguments of a go command, e.g. depth 8 nodes 100000
+function requestSearchLimits() {
+    let current = [];
+    if (state.limits.depth) current.push("depth " + state.limits.depth);
+    if (state.limits.nodes) current.push("nodes " + state.limits.nodes);
+    if (state.limits.searchmoves) current.push("searchmoves " + state.limits.searchmoves.join(" "));
+    let text = prompt("Search limits, e.g. depth 8 nodes 100000 searchmoves 2 3 4", current.join(" "));
+    if (text == null) return;
+    let args = text.trim().split(/\s+/);
+    let limits = {};
+    for (let i = 0; i < args.length; i++) {
+        switch (args[i].toLowerCase()) {
+        case "depth":
+            limits.depth = parseInt(args[++i]);
+            break;
+        case "nodes":
+            limits.nodes = parseInt(args[++i]);
+            break;
+        case "searchmoves":
+            limits.searchmoves = args.slice(i+1).map(Number).filter(move => !isNaN(move));
+            i = args.length;
+            break;
+        }
+    }
+    send("setlimits", limits);
 }
 
 function requestPause() {
-    if (state.playing) socket.send("pause");
+    if (state.playing) send("pause");
 }
 
 function requestPlay() {
-    if (!state.playing) socket.send("play");
+    if (!state.playing) send("play");
 }
 
 function requestEngineOperation(engineId, button) {
@@ -1047,37 +1341,57 @@ function requestEngineOperation(engineId, button) {
         requestPlayers(state.player1ID, engineId);
         break;
     case ENGINE_SETTINGS_BUTTON:
-        gui.showSettingsOverlay();
+        gui.showSettingsOverlay(engineId);
         requestEngineSettings(engineId);
         break
     case ENGINE_DISCONNECT_BUTTON:
         requestEngineUnload(engineId);
         break;
+    case ENGINE_RESTART_BUTTON:
+        requestEngineRestart(engineId, !state.engines["engine"+engineId].restart);
+        break;
+    case ENGINE_PONDER_BUTTON:
+        requestEnginePonder(engineId, !state.engines["engine"+engineId].ponder);
+        break;
     }
 }
 
 function requestPlayers(player1, player2) {
-    socket.send("setplayers player1 "+player1+" player2 "+player2);
+    send("setplayers", {player1: player1, player2: player2});
 }
 
 function requestEnginePaths() {
-    socket.send("enginepaths");
+    send("enginepaths");
 }
 
 function requestEngineLoad(path) {
-    socket.send("engine load path "+path);
+    send("loadengine", {path: path});
 }
 
 function requestEngineUnload(engineId) {
-    socket.send("engine unload id "+engineId);
+    send("unloadengine", {id: engineId});
+}
+
+function requestEngineRestart(engineId, enable) {
+    send("setrestart", {id: engineId, enabled: enable});
+}
+
+function requestEnginePonder(engineId, enable) {
+    send("setponder", {id: engineId, enabled: enable});
+}
+
+function requestSaveProfile(engineId) {
+    let name = prompt("Profile name");
+    if (!name) return;
+    send("saveprofile", {id: engineId, name: name});
 }
 
 function requestEngineSettings(engineId) {
-    socket.send("options engineid " + engineId);
+    send("options", {engineid: engineId});
 }
 
 function requestSetOption(setting) {
-    socket.send(setting.wsSetOptionCommand());
+    send("setoption", setting.setOptionPayload());
 }
 
 window.onload = () => {
@@ -1093,7 +1407,7 @@ window.onload = () => {
         state = new State();
         gui.updateButtons();
         drawloop();
-        socket.send("init");
+        send("init");
     }
     
     socket.onclose = (evt) => {
@@ -1111,13 +1425,6 @@ window.onload = () => {
 
 function drawloop() {
     gui.draw();
+    gui.drawChart();
     requestAnimationFrame(drawloop);
 }
-
-function getAllIndexes(arr, val) {
-    var indexes = [], i = -1;
-    while ((i = arr.indexOf(val, i+1)) != -1){
-        indexes.push(i);
-    }
-    return indexes;
-}
\ No newline at end of file
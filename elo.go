@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math"
+)
+
+// EloDifference estimates how much stronger, in Elo, a player is
+// than their opponent from the games they played against each other.
+// The margin is the 95% confidence interval either side of the
+// difference. Both are infinite if every game had the same result
+func EloDifference(wins, losses, draws int) (float64, float64) {
+	games := float64(wins + losses + draws)
+	if games == 0 {
+		return 0, math.Inf(1)
+	}
+	score := (float64(wins) + float64(draws)/2) / games
+	// The variance of the score of a single game
+	variance := (float64(wins)*math.Pow(1-score, 2) +
+		float64(losses)*math.Pow(score, 2) +
+		float64(draws)*math.Pow(0.5-score, 2)) / games
+	deviation := math.Sqrt(variance / games)
+	lower := eloFromScore(score - 1.96*deviation)
+	upper := eloFromScore(score + 1.96*deviation)
+	return eloFromScore(score), (upper - lower) / 2
+}
+
+// eloFromScore gets the Elo difference which
+// gives the expected score, between 0 and 1
+func eloFromScore(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
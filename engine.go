@@ -1,9 +1,8 @@
 package main
 
 import (
-	"os"
-	"os/exec"
-	"path/filepath"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,15 +19,68 @@ import (
 type Engine struct {
 	// Used for interacting with the engine
 	Path         string
-	cmd          *exec.Cmd
+	Definition   EngineDefinition
+	transport    Transport
 	communicator Protocol
+	protocol     func(Transport) (Protocol, error)
 	// Information provided by the engine
-	Name    string
-	Author  string
-	Options map[string]Option
+	Name   string
+	Author string
+	// options are the engine's options with their current values
+	options map[string]Option
+	// defaults are the options as they were specified
+	// by the engine during the handshake
+	defaults map[string]Option
 	// Current engine state
-	ready    bool
-	thinking bool
+	ready     bool
+	thinking  bool
+	pondering bool
+	debug     bool
+	// ponder decides whether the engine thinks
+	// while its opponent is thinking in games
+	ponder bool
+	// Channels set by NotifyInfo, NotifyComm, NotifyExit and
+	// NotifyStderr. They are kept so that they can be given
+	// to a restarted engine's communicator. notifyLock guards
+	// them and is held while sending on exits and stderr, so
+	// that nothing is sent on a channel once it has been unset
+	notifyLock sync.Mutex
+	info       chan<- string
+	comm       chan<- Communication
+	exits      chan<- ExitStatus
+	stderr     chan<- StderrLine
+	// Stderr holds the most recent lines the engine
+	// has written to stderr, including previous processes
+	// from before it was restarted
+	Stderr     *LineBuffer
+	stderrPipe io.ReadCloser
+	stderrDone chan struct{}
+	// commandLock is held while a command is sent to the engine and
+	// while it's being restarted, so that commands aren't sent part
+	// way through a restart and the restart doesn't interleave with them
+	commandLock sync.Mutex
+	// Process supervision
+	lock     sync.Mutex
+	exited   chan struct{}
+	quitting bool
+	killed   bool
+	breached string
+	// restarting is true while the engine is being restarted. The exit
+	// of a process started by a restart which fails is the restart's
+	// error rather than a crash of its own
+	restarting bool
+	// closed is true once the engine has been told to quit,
+	// after which it's never restarted
+	closed bool
+	// exitStatus describes how the engine's process terminated
+	// It is only valid once the Exited channel has been closed
+	exitStatus ExitStatus
+	// restartPolicy decides whether the engine is restarted
+	// after its process crashes
+	restartPolicy RestartPolicy
+	// crashes is the number of times the engine's process
+	// has terminated without being told to quit
+	crashes int
 }
 
 // NewEngine creates a new engine, esablishes a connection with it
@@ -38,67 +90,234 @@ type Engine struct {
 // be provided by the engine and extracted into the datastructure
 // If: the engine is not found; a connection couldn't be established
 // or the protocol handshake failed, an error will be returned
-func NewEngine(path string, protocol func(*exec.Cmd) (Protocol, error)) (*Engine, error) {
+func NewEngine(definition EngineDefinition, protocol func(Transport) (Protocol, error)) (*Engine, error) {
 	// Checking if the engine file exists
-	path = filepath.Join(EngineDirectory, path)
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, errors.Wrap(err, "couldn't find engine")
-	} else if err != nil {
-		return nil, errors.Wrap(err, "couldn't find engine")
+	path, err := definition.executable()
+	if err != nil {
+		return nil, err
 	}
 	// Making engine struct
 	engine := Engine{
-		Path:    path,
-		cmd:     exec.Command(path),
-		Options: make(map[string]Option),
+		Path:       path,
+		Definition: definition,
+		protocol:   protocol,
+		options:    make(map[string]Option),
+		Stderr:     NewLineBuffer(StderrBufferSize),
 	}
 	// Establishing connection to engine
-	var err error
-	engine.communicator, err = protocol(engine.cmd)
-	if err != nil {
-		return nil, errors.Wrap(err, "couldn't create communicator")
+	if err := engine.newProcess(); err != nil {
+		return nil, err
 	}
 	return &engine, nil
 }
 
+// newProcess prepares a new process or connection for the
+// engine along with a communicator connected to it. The process
+// still needs to be started
+func (e *Engine) newProcess() error {
+	transport, err := e.Definition.transport(e.Path)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create engine process")
+	}
+	communicator, err := e.protocol(transport)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create communicator")
+	}
+	stderr, err := transport.Stderr()
+	if err != nil {
+		return errors.Wrap(err, "couldn't aquire stderr pipe")
+	}
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	if e.info != nil {
+		communicator.NotifyInfo(e.info)
+	}
+	if e.comm != nil {
+		communicator.NotifyComm(e.comm)
+	}
+	e.lock.Lock()
+	e.transport = transport
+	e.communicator = communicator
+	e.stderrPipe = stderr
+	e.stderrDone = make(chan struct{})
+	e.exited = make(chan struct{})
+	e.quitting = false
+	e.killed = false
+	e.breached = ""
+	e.lock.Unlock()
+	return nil
+}
+
 // Load starts the engine process and performs a handshake
 // using the protocol implimentation of the communicator
 func (e *Engine) Load() error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	name, author, options, err := e.start()
+	if err != nil {
+		return err
+	}
+	// The definition's name takes precedence over the engine's
+	if e.Definition.Name != "" {
+		name = e.Definition.Name
+	}
+	e.lock.Lock()
+	e.Name, e.Author = name, author
+	e.lock.Unlock()
+	e.setOptions(options)
+	return nil
+}
+
+// setOptions replaces the engine's options with the
+// options it specified during its handshake
+func (e *Engine) setOptions(options map[string]Option) {
+	defaults := make(map[string]Option, len(options))
+	for k, v := range options {
+		defaults[k] = v
+	}
+	e.lock.Lock()
+	e.options = options
+	e.defaults = defaults
+	e.lock.Unlock()
+}
+
+// Options gets a copy of the engine's options with their current values
+func (e *Engine) Options() map[string]Option {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	result := make(map[string]Option, len(e.options))
+	for k, v := range e.options {
+		result[k] = v
+	}
+	return result
+}
+
+// Option gets one of the engine's options with its current value
+func (e *Engine) Option(name string) (Option, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	option, ok := e.options[name]
+	return option, ok
+}
+
+// start starts the engine process and performs a handshake.
+// The name, author and options specified by the engine
+// during the handshake are returned
+func (e *Engine) start() (string, string, map[string]Option, error) {
 	// Starting engine
-	if err := e.cmd.Start(); err != nil {
-		return errors.Wrap(err, "couldn't start engine")
+	if err := e.transport.Start(); err != nil {
+		return "", "", nil, errors.Wrap(err, "couldn't start engine")
+	}
+	// Watching the process for when it terminates
+	// and anything it writes to stderr
+	if e.stderrPipe != nil {
+		go e.readStderr(e.stderrPipe, e.stderrDone)
+	} else {
+		close(e.stderrDone)
+	}
+	go e.supervise()
+	// Restricting the resources the process can use
+	// Remote engines are limited by whatever is running them
+	if pid, limits := e.transport.Pid(), e.Definition.Limits; pid != 0 {
+		if err := applyLimits(pid, limits); err != nil {
+			e.stop()
+			<-e.Exited()
+			return "", "", nil, errors.Wrap(err, "couldn't apply resource limits")
+		}
+		go e.enforceLimits(pid, limits, e.Exited())
 	}
 	// Performing protocol handshake
+	var name, author string
+	options := make(map[string]Option)
 	err := e.communicator.Handshake(
-		&e.Name,
-		&e.Author,
-		&e.Options,
+		&name,
+		&author,
+		&options,
 	)
 	if err != nil {
-		return errors.Wrap(err, "protocol handshake failed")
+		// Making sure the process doesn't outlive the failed handshake
+		e.stop()
+		<-e.Exited()
+		// A breached limit is a more useful reason than the handshake
+		if limit := e.LastExit().Limit; limit != "" {
+			return "", "", nil, errors.Errorf("engine exceeded its %s limit", limit)
+		}
+		return "", "", nil, errors.Wrap(err, "protocol handshake failed")
 	}
 	// Engine started successfully
+	e.lock.Lock()
 	e.ready = true
-	return nil
+	e.lock.Unlock()
+	return name, author, options, nil
+}
+
+// isReady returns true if the engine has finished its
+// handshake and hasn't since been told to quit or terminated
+func (e *Engine) isReady() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.ready
 }
 
 // Debug enables and disables the engine's debug mode
 func (e *Engine) Debug(enable bool) error {
-	if !e.ready {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	return e.setDebug(enable)
+}
+
+// setDebug enables and disables the engine's debug
+// mode while commandLock is held
+func (e *Engine) setDebug(enable bool) error {
+	if !e.isReady() {
 		return errors.New("engine is not ready")
 	}
-	return e.communicator.Debug(enable)
+	if err := e.communicator.Debug(enable); err != nil {
+		return err
+	}
+	e.lock.Lock()
+	e.debug = enable
+	e.lock.Unlock()
+	return nil
+}
+
+// LoadedName gets the engine's name, which can be read
+// while it's being loaded by handlers of its events
+func (e *Engine) LoadedName() string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.Name
+}
+
+// Debugging returns true if the engine's debug mode is enabled
+func (e *Engine) Debugging() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.debug
 }
 
 // SetOption sets an internal parameter of the engine
 func (e *Engine) SetOption(o Option) error {
-	if !e.ready {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	return e.setOption(o)
+}
+
+// setOption sets an internal parameter of the
+// engine while commandLock is held
+func (e *Engine) setOption(o Option) error {
+	if !e.isReady() {
 		return errors.New("engine is not ready")
 	}
-	if _, ok := e.Options[o.OptionName()]; !ok {
+	e.lock.Lock()
+	_, ok := e.options[o.OptionName()]
+	if ok {
+		e.options[o.OptionName()] = o
+	}
+	e.lock.Unlock()
+	if !ok {
 		return errors.New("option not specified by engine")
 	}
-	e.Options[o.OptionName()] = o
 	return e.communicator.SetOption(o)
 }
 
@@ -106,7 +325,9 @@ func (e *Engine) SetOption(o Option) error {
 // will receive is from a different game to the
 // previous position it was provided
 func (e *Engine) NewGame() error {
-	if !e.ready {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
 		return errors.New("engine is not ready")
 	}
 	return e.communicator.NewGame()
@@ -114,7 +335,9 @@ func (e *Engine) NewGame() error {
 
 // Position gives the engine a new position to analyse
 func (e *Engine) Position(s State) error {
-	if !e.ready {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
 		return errors.New("engine is not ready")
 	}
 	return e.communicator.Position(s)
@@ -125,57 +348,203 @@ func (e *Engine) Position(s State) error {
 // If moveTime is positive, the engine will be told that it
 // has moveTime seconds to analyse the position before it
 // will be asked to stop and provide its best move
-func (e *Engine) Go(moveTime time.Duration) error {
-	if !e.ready {
+// The search is also restricted by limits
+func (e *Engine) Go(moveTime time.Duration, limits SearchLimits) error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
 		return errors.New("engine is not ready")
 	}
-	if e.thinking {
+	if !e.startThinking(false) {
+		return errors.New("engine is thinking")
+	}
+	return e.communicator.Go(moveTime, limits)
+}
+
+// GoPonder tells the engine to start analysing the
+// position as if move had been played in it
+func (e *Engine) GoPonder(move int) error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
+		return errors.New("engine is not ready")
+	}
+	if !e.startThinking(true) {
 		return errors.New("engine is thinking")
 	}
+	return e.communicator.GoPonder(move)
+}
+
+// startThinking records that the engine has been told to
+// search, returning false if it's already searching
+func (e *Engine) startThinking(ponder bool) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.thinking {
+		return false
+	}
 	e.thinking = true
-	return e.communicator.Go(moveTime)
+	e.pondering = ponder
+	return true
+}
+
+// SetPonder sets whether the engine thinks while
+// its opponent is thinking in games
+func (e *Engine) SetPonder(enable bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.ponder = enable
+}
+
+// Ponders returns true if the engine thinks while
+// its opponent is thinking in games
+func (e *Engine) Ponders() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.ponder
+}
+
+// Pondering returns true if the engine has been told to ponder
+// and hasn't since been stopped, had a ponder hit or terminated
+func (e *Engine) Pondering() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.pondering
+}
+
+// PonderHit tells a pondering engine that the move it was
+// pondering on was played. The engine carries on analysing
+// as if it had been told to go with moveTime
+func (e *Engine) PonderHit(moveTime time.Duration) error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
+		return errors.New("engine is not ready")
+	}
+	e.lock.Lock()
+	pondering := e.pondering
+	e.pondering = false
+	e.lock.Unlock()
+	if !pondering {
+		return errors.New("engine is not pondering")
+	}
+	return e.communicator.PonderHit(moveTime)
+}
+
+// PonderMove gets the move the engine expects its opponent
+// to reply to its last best move with. This is the move it
+// suggested pondering on or otherwise the second move of
+// its principal variation. false is returned if there is none
+func (e *Engine) PonderMove() (int, bool) {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if move, ok := e.communicator.PonderMove(); ok {
+		return move, true
+	}
+	if info, ok := e.communicator.LastInfo(); ok && len(info.PV) >= 2 {
+		return info.PV[1], true
+	}
+	return 0, false
 }
 
 // Stop tells the engine to stop analysing the position
 // as soon as posible and to provide a best move
 func (e *Engine) Stop() (int, error) {
-	if !e.ready {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	if !e.isReady() {
 		return 0, errors.New("engine is not ready")
 	}
-	if !e.thinking {
+	e.lock.Lock()
+	thinking := e.thinking
+	e.thinking = false
+	e.pondering = false
+	e.lock.Unlock()
+	if !thinking {
 		return 0, errors.New("engine is not thinking")
 	}
-	e.thinking = false
 	bestMove, err := e.communicator.Stop()
 	return bestMove, err
 }
 
 // Quit tells the engine to exit as soon as possible
-// then terminates the process
-// If the engine doesn't quit by itself, the program
-// will hang here. As killing the process seems
-// a little excessive and possibly dangerous
-// I may change this after a little more research
+// then waits for the process to terminate
+// If the engine doesn't quit within its QuitTimeout,
+// the process is killed
 func (e *Engine) Quit() error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	// Once it has been told to quit, the engine isn't restarted
+	e.lock.Lock()
+	e.closed = true
+	e.lock.Unlock()
+	// An engine that has already terminated has nothing to quit
+	if e.HasExited() {
+		return nil
+	}
+	e.lock.Lock()
 	if !e.ready {
+		e.lock.Unlock()
 		return errors.New("engine is not ready")
 	}
 	e.ready = false
-	err := e.communicator.Quit()
-	if err != nil {
-		return errors.Wrap(err, "couldn't stop engine communicator")
+	e.quitting = true
+	e.lock.Unlock()
+	quitErr := e.communicator.Quit()
+	// Whether or not the engine received the quit command,
+	// the process needs to terminate. If it didn't receive it
+	// there's no point waiting around for it to exit by itself
+	grace := e.QuitTimeout()
+	if quitErr != nil {
+		grace = 0
+	}
+	if err := e.waitForExit(grace); err != nil {
+		return errors.Wrap(err, "couldn't kill engine")
 	}
-	return e.cmd.Wait() // goroutine may hang here
+	if quitErr != nil {
+		return errors.Wrap(quitErr, "couldn't stop engine communicator")
+	}
+	return nil
+}
+
+// LastInfo gets the latest structured information the
+// engine reported about its current or most recent search
+func (e *Engine) LastInfo() (SearchInfo, bool) {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	return e.communicator.LastInfo()
 }
 
 // NotifyInfo sets the channel in which any information
 // from the engine should be sent to
 func (e *Engine) NotifyInfo(channel chan<- string) {
-	e.communicator.NotifyInfo(channel)
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	e.info = channel
+	e.currentCommunicator().NotifyInfo(channel)
 }
 
 // NotifyComm sets the channel in which communications between
 // the protocol implimentation and the engine should be send to
 func (e *Engine) NotifyComm(channel chan<- Communication) {
-	e.communicator.NotifyComm(channel)
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	e.comm = channel
+	e.currentCommunicator().NotifyComm(channel)
+}
+
+// currentCommunicator gets the communicator
+// of the engine's current process
+func (e *Engine) currentCommunicator() Protocol {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.communicator
+}
+
+// NotifyExit sets the channel in which the exit status of
+// the engine's process should be sent to whenever it terminates
+func (e *Engine) NotifyExit(channel chan<- ExitStatus) {
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	e.exits = channel
 }
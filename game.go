@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,6 +13,35 @@ const (
 	// that a player will be given to analyse a position
 	// before being asked to provide a move
 	DefaultTurnTime = 5 * time.Second
+	// crashDetectionTimeout is the maximum amount of time to wait
+	// for an engine's process to be reported as terminated after
+	// an error has occured while communicating with it
+	crashDetectionTimeout = 1 * time.Second
+)
+
+// The reasons a game can be over
+const (
+	// ReasonConnectFour is when a player connected four tiles
+	ReasonConnectFour = "connect-four"
+	// ReasonBoardFull is when the board filled up without
+	// either player connecting four tiles
+	ReasonBoardFull = "board full"
+	// ReasonIllegalMove is when a player's engine made an illegal
+	// move or replied with a move that couldn't be parsed
+	ReasonIllegalMove = "illegal move"
+	// ReasonTimeForfeit is when a player ran out of time or
+	// their engine didn't reply with a move in time
+	ReasonTimeForfeit = "time forfeit"
+	// ReasonCrash is when a player's engine terminated
+	ReasonCrash = "crash"
+	// ReasonAdjudication is when the game was ended
+	// early without being played to completion
+	ReasonAdjudication = "adjudication"
+	// ReasonResignation is when a player gave up
+	ReasonResignation = "resignation"
+	// ReasonUserAbort is when the user stopped the game
+	// before it finished, leaving it without a result
+	ReasonUserAbort = "user abort"
 )
 
 // Game is an environment for two players to play a game of
@@ -32,14 +63,43 @@ type Game struct {
 	// to analyse a position before being asked to provide
 	// a move
 	TurnTime time.Duration
+	// TimeControl, if it isn't nil, is used instead of TurnTime
+	// to decide how long players are given for each move
+	TimeControl *TimeControl
+	// Clocks are the time player1 and player2 have left
+	// when playing with a TimeControl
+	Clocks [2]time.Duration
+	// Times are the total time player1 and player2
+	// have taken to make their moves
+	Times [2]time.Duration
+	// Adjudication, if it isn't nil, ends the game early
+	// once the result is clear
+	Adjudication *Adjudication
+	// Limits restrict the players' searches. Players still
+	// have TurnTime, so it should be long enough for their
+	// searches to reach the limits
+	Limits SearchLimits
+	// Searches are what the players reported about their search
+	// for each move. Searches[i] led from History[i] to History[i+1]
+	Searches []MoveSearch
+	// pondering is what player1 and player2 are
+	// pondering on while their opponent thinks
+	pondering [2]ponder
 
 	// State is the current state of the board
 	State State
 	// History is the positions that have been visited over
-	// the course of the game
-	History [42]State
+	// the course of the game, the starting position and
+	// a position for each of the 42 tiles
+	History [43]State
 	// HistoryIndex is the index of the current state in History
 	HistoryIndex int
+	// Outcome is how the game finished, nil until it has
+	Outcome *GameOverEvent
+	// lock is held by the game loop while it changes State,
+	// History, Times, Searches and Outcome, so that they can
+	// be copied with Record while the game is being played
+	lock sync.Mutex
 
 	// Running tracks whether the gameloop is running or not
 	Running bool
@@ -53,15 +113,68 @@ type Game struct {
 	Events chan<- GameEvent
 }
 
+// GameRecord is a copy of the moves of a game
+type GameRecord struct {
+	// History is the positions of the game, the last
+	// of which is the current position
+	History []State
+	// Times are the total time each player has taken
+	Times [2]time.Duration
+	// Searches are how the players found each move
+	Searches []MoveSearch
+	// Outcome is how the game finished, nil until it has
+	Outcome *GameOverEvent
+}
+
+// Winner gets the winner of the current position
+func (r GameRecord) Winner() int {
+	return r.History[len(r.History)-1].Winner
+}
+
+// Record copies the moves of the game. It can be
+// used while the game is being played
+func (g *Game) Record() GameRecord {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	result := GameRecord{
+		History:  append([]State{}, g.History[:g.HistoryIndex+1]...),
+		Times:    g.Times,
+		Searches: append([]MoveSearch{}, g.Searches...),
+	}
+	if g.Outcome != nil {
+		outcome := *g.Outcome
+		result.Outcome = &outcome
+	}
+	return result
+}
+
+// ponder is a move a player is pondering on
+type ponder struct {
+	active bool
+	move   int
+}
+
 // GameEvent is an interface that allows multiple types of events
 // to be handled using the same channel
 type GameEvent interface {
 	GameEvent()
 }
 
+// MoveSearch is what a player reported about its search for a
+// move, along with the time it took to make the move. Scores are
+// from player1's point of view so both players' can be compared
+type MoveSearch struct {
+	Depth    int
+	HasScore bool
+	Score    int
+	Time     time.Duration
+}
+
 // NewStateEvent is triggered when a new position is reached
+// Search is how the player found the move that reached it
 type NewStateEvent struct {
-	State State
+	State  State
+	Search MoveSearch
 }
 
 // GameEvent allows NewStateEvent to impliment the GameEvent interface
@@ -69,12 +182,79 @@ func (NewStateEvent) GameEvent() {}
 
 // GameOverEvent is triggered when the game finishes
 type GameOverEvent struct {
+	// Winner is Player1, Player2, Tie or Empty
+	// if the game was aborted without a result
 	Winner int
+	// Reason is one of the Reason constants
+	Reason string
+	// Detail describes the reason further, e.g. the
+	// illegal move or how the engine terminated
+	Detail string
+	// Line is the indices of the four connected tiles
+	// when the game was won by connecting four
+	Line []int
+	// Moves is the number of moves that were played
+	Moves int
+	// Times are the total time each player took
+	Times [2]time.Duration
+}
+
+// Description describes the result of the game
+// and why it ended, e.g. Player1 wins by connect-four
+func (e GameOverEvent) Description() string {
+	var result string
+	switch e.Winner {
+	case Player1, Player2:
+		result = fmt.Sprintf("Player%d wins by %s", e.Winner+1, e.Reason)
+	case Tie:
+		result = "Draw by " + e.Reason
+	default:
+		result = "No result by " + e.Reason
+	}
+	if e.Detail != "" {
+		result += ", " + e.Detail
+	}
+	return result
 }
 
 // GameEvent allows GameOverEvent to impliment the GameEvent interface
 func (GameOverEvent) GameEvent() {}
 
+// TimeForfeitEvent is triggered when a player runs out of
+// time on their clock. The player who ran out of time loses
+type TimeForfeitEvent struct {
+	Player int
+	// Over is how much longer the player took than they had
+	Over time.Duration
+}
+
+// GameEvent allows TimeForfeitEvent to impliment the GameEvent interface
+func (TimeForfeitEvent) GameEvent() {}
+
+// ForfeitEvent is triggered when a player loses because their
+// engine didn't reply to stop with a legal move. The engine
+// either made an illegal move, didn't reply in time or replied
+// with something that couldn't be parsed
+type ForfeitEvent struct {
+	Player int
+	// Reason describes what the engine did wrong
+	Reason string
+}
+
+// GameEvent allows ForfeitEvent to impliment the GameEvent interface
+func (ForfeitEvent) GameEvent() {}
+
+// EngineExitEvent is triggered when a player's engine process
+// terminates while the game is being played. The player whose
+// engine terminated loses the game
+type EngineExitEvent struct {
+	Player int
+	Status ExitStatus
+}
+
+// GameEvent allows EngineExitEvent to impliment the GameEvent interface
+func (EngineExitEvent) GameEvent() {}
+
 // ErrorEvent is triggered when an error occurs when playing game
 type ErrorEvent struct {
 	Error error
@@ -83,13 +263,13 @@ type ErrorEvent struct {
 // GameEvent allows ErrorEvent to impliment the GameEvent interface
 func (ErrorEvent) GameEvent() {}
 
-// NewGame returns a new game with the default timeout options
-// and a new starting position
-func NewGame() *Game {
+// NewGame returns a new game where players are given turnTime
+// to make each move and a new starting position
+func NewGame(turnTime time.Duration) *Game {
 	return &Game{
-		TurnTime:    DefaultTurnTime,
+		TurnTime:    turnTime,
 		State:       NewState(),
-		History:     [42]State{NewState()},
+		History:     [43]State{NewState()},
 		PauseSignal: make(chan bool),
 	}
 }
@@ -148,6 +328,19 @@ func (g *Game) SetTimeout(time time.Duration) error {
 	return nil
 }
 
+// SetLimits sets the limits of the players' searches
+func (g *Game) SetLimits(limits SearchLimits) error {
+	// Return an error if the game is running
+	if g.Running {
+		return errors.New("cannot set search limits while game is being played")
+	}
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	g.Limits = limits
+	return nil
+}
+
 // Reset sets the game back to a starting position
 func (g *Game) Reset() error {
 	// Return an error if the game is running
@@ -166,13 +359,79 @@ func (g *Game) Position(s State) error {
 	}
 	// Set up the game state
 	g.State = s
-	g.History = [42]State{s}
+	g.History = [43]State{s}
 	g.HistoryIndex = 0
+	g.Outcome = nil
+	g.Times = [2]time.Duration{}
+	g.Searches = nil
+	g.Player1Status = -1
+	g.Player2Status = -1
+	g.resetClocks()
+	return nil
+}
+
+// resetClocks gives both players the base
+// time of the time control, if there is one
+func (g *Game) resetClocks() {
+	if g.TimeControl != nil {
+		g.Clocks = [2]time.Duration{g.TimeControl.Base, g.TimeControl.Base}
+	}
+}
+
+// Restore sets the game to a sequence of positions that have
+// already been played. The last position is the current state
+// and winner overrides its winner, allowing adjudicated games
+// to be restored. reason is why the game finished, if it did
+// searches are the searches for each move. Moves without
+// a search are given an empty one
+func (g *Game) Restore(history []State, searches []MoveSearch, winner int, reason string) error {
+	// Return an error if the game is running
+	if g.Running {
+		return errors.New("cannot restore game while game is being played")
+	}
+	if len(history) == 0 || len(history) > len(g.History) {
+		return errors.New("invalid number of positions")
+	}
+	// Set up the game state
+	g.History = [43]State{}
+	copy(g.History[:], history)
+	g.HistoryIndex = len(history) - 1
+	natural := g.History[g.HistoryIndex].Winner
+	g.History[g.HistoryIndex].Winner = winner
+	g.State = g.History[g.HistoryIndex]
+	g.Outcome = nil
+	g.Times = [2]time.Duration{}
+	g.Searches = make([]MoveSearch, g.HistoryIndex)
+	copy(g.Searches, searches)
 	g.Player1Status = -1
 	g.Player2Status = -1
+	if winner == Empty {
+		return nil
+	}
+	// Without a reason, a winner other than the position's
+	// own can only have come from an adjudication
+	switch {
+	case reason != "":
+		g.finish(reason, "")
+	case winner == natural:
+		g.finishPosition()
+	default:
+		g.finish(ReasonAdjudication, "")
+	}
 	return nil
 }
 
+// Abort ends a game which was started but hasn't finished without
+// a result, e.g. when the user starts a new game part way through
+// false is returned if there is no such game to abort
+func (g *Game) Abort() (GameOverEvent, bool) {
+	if g.Running || g.Outcome != nil || g.HistoryIndex == 0 {
+		return GameOverEvent{}, false
+	}
+	g.finish(ReasonUserAbort, "")
+	return *g.Outcome, true
+}
+
 // Play runs the game to completion, using player1 and player2 to
 // provide moves in each board state
 func (g *Game) Play() error {
@@ -184,6 +443,10 @@ func (g *Game) Play() error {
 	if g.Player1 == nil || g.Player2 == nil {
 		return errors.New("cannot play game when player is nil")
 	}
+	// Return an error if the game has already finished
+	if g.Outcome != nil {
+		return errors.New("game is over")
+	}
 	// Set the running state of the game
 	g.Running = true
 	// Start gameloop
@@ -229,8 +492,33 @@ func (g *Game) currentPlayer() (*Engine, error) {
 func (g *Game) gameLoop() {
 	// Loop until the game is finished or the running state changes
 	for g.State.Winner == Empty && g.Running {
+		// The players' current processes are noted before the turn so
+		// that a crash is noticed even if the engine is restarted
+		exited1, exited2 := g.playerExits()
 		// Play out a turn and return errors if they arise
 		completed, err := g.playTurn()
+		// If an engine crashed during the turn, the game is
+		// adjudicated as a loss for that player. Errors are
+		// usually the first sign of a crash so the process is
+		// given a little time to be reported as terminated
+		wait := time.Duration(0)
+		if err != nil {
+			wait = crashDetectionTimeout
+		}
+		if player, engine := g.crashedPlayer(exited1, exited2, wait); engine != nil {
+			status := engine.LastExit()
+			g.forfeit(player, ReasonCrash, fmt.Sprintf("engine %s", status))
+			if g.Events != nil {
+				g.Events <- EngineExitEvent{
+					Player: player,
+					Status: status,
+				}
+			}
+			break
+		}
+		if err != nil {
+			g.stopPondering()
+		}
 		if err != nil && g.Events != nil {
 			g.Events <- ErrorEvent{
 				Error: errors.Wrap(err, "couldn't play turn"),
@@ -241,11 +529,17 @@ func (g *Game) gameLoop() {
 			return
 		}
 		if g.Events != nil && completed {
-			g.Events <- NewStateEvent{State: g.State}
+			g.Events <- NewStateEvent{State: g.State, Search: g.Searches[len(g.Searches)-1]}
+		}
+		// The game can be ended early once its result is clear
+		if completed && g.Adjudication != nil {
+			g.adjudicate()
 		}
 	}
-	if g.Events != nil && g.State.Winner != Empty {
-		g.Events <- GameOverEvent{Winner: g.State.Winner}
+	// The players stop pondering before anything else uses them
+	g.stopPondering()
+	if g.Events != nil && g.Outcome != nil {
+		g.Events <- *g.Outcome
 	}
 	g.Running = false
 }
@@ -258,24 +552,46 @@ func (g *Game) playTurn() (bool, error) {
 	if g.State.Winner != Empty {
 		return false, errors.New("unable to play turn when game is over")
 	}
-	// Update the engines' internal states
-	err := g.updateEngineStates()
-	if err != nil {
-		return false, errors.Wrap(err, "couldn't update engine states")
-	}
 	// Get the player that is to make the next move
 	player, err := g.currentPlayer()
 	if err != nil {
 		return false, errors.Wrap(err, "couldn't get current player")
 	}
-	// Get the player to analyse the current position
-	err = player.Go(g.TurnTime)
+	// Tell the player whether the move it pondered on was played
+	hit, err := g.resolvePonder(player)
+	if err != nil {
+		if g.forfeitReply(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "couldn't stop player pondering")
+	}
+	// Update the engines' internal states
+	err = g.updateEngineStates()
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't update engine states")
+	}
+	// Get the player to analyse the current position. A player
+	// whose ponder was a hit carries on with its search. Either
+	// way, its time only starts once it has been told to move
+	moveTime := g.TurnTime
+	if g.TimeControl != nil {
+		moveTime = g.TimeControl.MoveTime(g.Clocks[g.State.Player], g.State.Turn)
+	}
+	if hit {
+		err = player.PonderHit(moveTime)
+	} else {
+		err = player.Go(moveTime, g.Limits.Position(g.State))
+	}
 	if err != nil {
 		return false, errors.Wrap(err, "failed to start player analysis")
 	}
+	started := time.Now()
 	// Wait for a pause signal or the timeout to pass
 	select {
-	case <-time.After(g.TurnTime):
+	case <-player.Exited():
+		// The player's process terminated while thinking
+		return false, errors.New("player terminated while thinking")
+	case <-time.After(moveTime):
 	case <-g.PauseSignal:
 		// If a pause signal is sent, stop the play from thinking
 		_, err := player.Stop()
@@ -285,23 +601,309 @@ func (g *Game) playTurn() (bool, error) {
 		// Good return, turn was interupted by pause
 		return false, nil
 	}
-	// Get the player from the player
+	// Get the move from the player
 	move, err := player.Stop()
 	if err != nil {
+		if g.forfeitReply(err) {
+			return false, nil
+		}
 		return false, errors.Wrap(err, "unable to get move from player")
 	}
+	// Take the time the player used off their clock
+	took := time.Since(started)
+	g.lock.Lock()
+	g.Times[g.State.Player] += took
+	g.lock.Unlock()
+	if g.TimeControl != nil && !g.useClock(g.State.Player, took) {
+		return false, nil
+	}
 	// Apply the move to the current state
-	g.State, err = g.State.NextState(move)
+	// A player who makes an illegal move loses the game
+	next, err := g.State.NextState(move)
 	if err != nil {
-		return false, errors.Wrap(err, "unable to apply move")
+		g.forfeitTurn(ReasonIllegalMove, fmt.Sprintf("illegal move %d", move))
+		return false, nil
 	}
+	// Keep what the player reported about its search
+	search := playerSearch(player, g.State.Player, took)
+	g.lock.Lock()
+	g.State = next
+	g.Searches = append(g.Searches, search)
 	// Update the history of the game
 	g.HistoryIndex++
 	g.History[g.HistoryIndex] = g.State
+	if g.State.Winner != Empty {
+		g.finishPosition()
+	}
+	g.lock.Unlock()
+	if g.State.Winner != Empty {
+		return true, nil
+	}
+	// Let the player think while their opponent does
+	if err := g.startPondering(player); err != nil {
+		return true, errors.Wrap(err, "couldn't start player pondering")
+	}
 	// Good return, turn wasn't interupted
 	return true, nil
 }
 
+// forfeitReply ends the game with the current player losing if the
+// error shows that their engine didn't reply to stop with a move.
+// false is returned if the error was for any other reason
+func (g *Game) forfeitReply(err error) bool {
+	switch v := errors.Cause(err).(type) {
+	case TimeoutError:
+		g.forfeitTurn(ReasonTimeForfeit, v.Error())
+		return true
+	case UnparseableReplyError:
+		g.forfeitTurn(ReasonIllegalMove, v.Error())
+		return true
+	}
+	return false
+}
+
+// startPondering has a player which has just moved ponder on the
+// move it expects its opponent to make, if the player ponders.
+// Players don't ponder when they are also their own opponent,
+// or when their searches are limited so that they can be repeated
+func (g *Game) startPondering(e *Engine) error {
+	if !e.Ponders() || g.Player1 == g.Player2 || g.Limits.Limited() {
+		return nil
+	}
+	move, ok := e.PonderMove()
+	if !ok || move < 0 || move > 6 || !g.State.LegalActions()[move] {
+		return nil
+	}
+	// The player is told about the position its move reached
+	player := 1 - g.State.Player
+	if err := g.updateEngineState(e, g.status(player)); err != nil {
+		return err
+	}
+	g.setStatus(player, g.HistoryIndex)
+	if err := e.GoPonder(move); err != nil {
+		return err
+	}
+	g.pondering[player] = ponder{active: true, move: move}
+	return nil
+}
+
+// resolvePonder tells the current player, if it's pondering,
+// whether the move it pondered on was played. On a ponder hit,
+// true is returned and the player is left searching. On a miss,
+// its search is stopped and the best move is ignored
+func (g *Game) resolvePonder(e *Engine) (bool, error) {
+	p := g.pondering[g.State.Player]
+	if !p.active {
+		return false, nil
+	}
+	g.pondering[g.State.Player] = ponder{}
+	// The engine is no longer pondering if it was restarted
+	// since, so there is nothing to stop or carry on with
+	if !e.Pondering() {
+		return false, nil
+	}
+	expected, err := g.History[g.HistoryIndex-1].NextState(p.move)
+	if err == nil && expected.Tiles == g.State.Tiles {
+		// The player already knows the position
+		g.setStatus(g.State.Player, g.HistoryIndex)
+		return true, nil
+	}
+	_, err = e.Stop()
+	return false, err
+}
+
+// stopPondering stops any player which is pondering
+// The best moves they found are ignored
+func (g *Game) stopPondering() {
+	for player, p := range g.pondering {
+		if !p.active {
+			continue
+		}
+		g.pondering[player] = ponder{}
+		if e := g.player(player); e != nil && !e.HasExited() {
+			e.Stop()
+		}
+	}
+}
+
+// player gets the engine playing as player
+func (g *Game) player(player int) *Engine {
+	if player == Player1 {
+		return g.Player1
+	}
+	return g.Player2
+}
+
+// status gets the index in History of the
+// position player has in its internal state
+func (g *Game) status(player int) int {
+	if player == Player1 {
+		return g.Player1Status
+	}
+	return g.Player2Status
+}
+
+// setStatus sets the index in History of the
+// position player has in its internal state
+func (g *Game) setStatus(player, status int) {
+	if player == Player1 {
+		g.Player1Status = status
+	} else {
+		g.Player2Status = status
+	}
+}
+
+// playerExits returns the channels which are closed when the
+// players' current processes terminate. Receiving from the nil
+// channel returned for an unset player blocks forever
+func (g *Game) playerExits() (<-chan struct{}, <-chan struct{}) {
+	var player1Exited, player2Exited <-chan struct{}
+	if g.Player1 != nil {
+		player1Exited = g.Player1.Exited()
+	}
+	if g.Player2 != nil {
+		player2Exited = g.Player2.Exited()
+	}
+	return player1Exited, player2Exited
+}
+
+// crashedPlayer returns the player whose engine's process has
+// terminated along with the engine. If neither have terminated
+// within the wait duration, the engine will be nil.
+// If both players are the same engine, the current player is
+// the one who crashed
+func (g *Game) crashedPlayer(player1Exited, player2Exited <-chan struct{}, wait time.Duration) (int, *Engine) {
+	// crashed gets the result for when player1 or player2 has exited
+	crashed := func(player int) (int, *Engine) {
+		if g.Player1 == g.Player2 {
+			return g.State.Player, g.Player1
+		}
+		if player == Player1 {
+			return Player1, g.Player1
+		}
+		return Player2, g.Player2
+	}
+	// Checking if either player has already exited
+	select {
+	case <-player1Exited:
+		return crashed(Player1)
+	case <-player2Exited:
+		return crashed(Player2)
+	default:
+	}
+	if wait <= 0 {
+		return Empty, nil
+	}
+	// Giving the players time to be reported as exited
+	select {
+	case <-player1Exited:
+		return crashed(Player1)
+	case <-player2Exited:
+		return crashed(Player2)
+	case <-time.After(wait):
+		return Empty, nil
+	}
+}
+
+// useClock takes the time a player took to make a move off their
+// clock and then adds the increment. If the player ran out of time,
+// they forfeit the game and false is returned
+func (g *Game) useClock(player int, took time.Duration) bool {
+	g.Clocks[player] -= took
+	if g.Clocks[player] < 0 {
+		over := -g.Clocks[player]
+		g.Clocks[player] = 0
+		g.forfeit(player, ReasonTimeForfeit, fmt.Sprintf("ran out of time by %s", over))
+		if g.Events != nil {
+			g.Events <- TimeForfeitEvent{Player: player, Over: over}
+		}
+		return false
+	}
+	g.Clocks[player] += g.TimeControl.Increment
+	return true
+}
+
+// forfeit ends the game with the provided player losing
+func (g *Game) forfeit(player int, reason, detail string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if player == Player1 {
+		g.State.Winner = Player2
+		g.Player1Status = -1
+	} else {
+		g.State.Winner = Player1
+		g.Player2Status = -1
+	}
+	g.History[g.HistoryIndex] = g.State
+	g.finish(reason, detail)
+}
+
+// forfeitTurn ends the game with the current player losing
+// because their engine didn't reply with a legal move
+func (g *Game) forfeitTurn(reason, detail string) {
+	player := g.State.Player
+	g.forfeit(player, reason, detail)
+	if g.Events != nil {
+		g.Events <- ForfeitEvent{Player: player, Reason: detail}
+	}
+}
+
+// adjudicate ends the game early if the
+// adjudication decides that the result is clear
+func (g *Game) adjudicate() {
+	winner, detail, ok := g.Adjudication.adjudicate(g.State, g.Searches)
+	if !ok {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.State.Winner = winner
+	g.History[g.HistoryIndex] = g.State
+	g.finish(ReasonAdjudication, detail)
+}
+
+// playerSearch gets what a player's engine reported about its
+// search of the position it moved in, taking took to move
+func playerSearch(e *Engine, player int, took time.Duration) MoveSearch {
+	result := MoveSearch{Time: took}
+	info, ok := e.LastInfo()
+	if !ok {
+		return result
+	}
+	result.Depth = info.Depth
+	if !info.HasScore {
+		return result
+	}
+	result.HasScore = true
+	result.Score = info.Value()
+	if player == Player2 {
+		result.Score = -result.Score
+	}
+	return result
+}
+
+// finishPosition records the outcome of a game which
+// finished because of the tiles on the board
+func (g *Game) finishPosition() {
+	if line := g.State.WinningLine(); line != nil {
+		g.finish(ReasonConnectFour, "")
+		g.Outcome.Line = line
+	} else {
+		g.finish(ReasonBoardFull, "")
+	}
+}
+
+// finish records the outcome of the game
+func (g *Game) finish(reason, detail string) {
+	g.Outcome = &GameOverEvent{
+		Winner: g.State.Winner,
+		Reason: reason,
+		Detail: detail,
+		Moves:  g.HistoryIndex,
+		Times:  g.Times,
+	}
+}
+
 // updateEngineStatuses sends relevent information to the players
 // to keep their internal state in sync with the current game state
 func (g *Game) updateEngineStates() error {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GameLog is a file which records everything that
+// happens over the course of a single game
+type GameLog struct {
+	lock sync.Mutex
+	file *os.File
+	// Path is the path to the log file
+	Path string
+}
+
+// NewGameLog creates a new log file within LogDirectory
+// The file is named after the time the game started
+func NewGameLog() (*GameLog, error) {
+	if err := os.MkdirAll(LogDirectory, 0755); err != nil {
+		return nil, errors.Wrap(err, "couldn't make log directory")
+	}
+	now := time.Now()
+	path := filepath.Join(LogDirectory, fmt.Sprintf(
+		"game-%04d%02d%02d-%02d%02d%02d-%09d.log",
+		now.Year(), now.Month(), now.Day(),
+		now.Hour(), now.Minute(), now.Second(), now.Nanosecond(),
+	))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create log file")
+	}
+	return &GameLog{file: file, Path: path}, nil
+}
+
+// Write adds a line to the log
+// The log is best effort so errors are ignored
+func (l *GameLog) Write(t time.Time, sender, message string) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	fmt.Fprintf(l.file, "[%s][%s]: %s\n", FormatTime(t), sender, message)
+}
+
+// Close closes the log file
+func (l *GameLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MateScore is the score given to a forced win when comparing
+// scores. A win in fewer moves has a larger score
+const MateScore = 100000
+
+// MultiPVOption is the name of the standard option which sets how
+// many of its best moves an engine reports on, each in its own line
+// of info. Engines which support it declare it as a spin option
+const MultiPVOption = "MultiPV"
+
+// SearchInfo is the structured information an engine can report
+// about its search with an info command, for example
+// info depth 12 score 35 nodes 120000 pv 3 3 4
+// Scores are from the point of view of the player to move
+type SearchInfo struct {
+	// MultiPV is the rank of the line when the engine is reporting
+	// on several moves, 1 being its best move. It's zero when the
+	// engine didn't say, which is treated as the best move
+	MultiPV int
+	// Depth is how many moves ahead the engine has searched
+	Depth int
+	// HasScore is whether the engine reported a score
+	HasScore bool
+	// Score is the evaluation of the position
+	Score int
+	// Mate, if it isn't zero, is the number of moves until a
+	// forced win, negative if the player to move is losing
+	Mate  int
+	Nodes int
+	// PV is the moves the engine expects to be played
+	PV []int
+}
+
+// ParseSearchInfo parses the arguments of an info command. As an
+// info command can be any message, false is returned if it doesn't
+// contain any structured information. Unknown tokens are ignored
+func ParseSearchInfo(args []string) (SearchInfo, bool) {
+	var (
+		result SearchInfo
+		found  bool
+	)
+	// integer parses the argument after the keyword at i
+	integer := func(i int) (int, bool) {
+		if i+1 >= len(args) {
+			return 0, false
+		}
+		v, err := strconv.Atoi(args[i+1])
+		return v, err == nil
+	}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "depth":
+			if v, ok := integer(i); ok {
+				result.Depth, found = v, true
+				i++
+			}
+		case "nodes":
+			if v, ok := integer(i); ok {
+				result.Nodes, found = v, true
+				i++
+			}
+		case "multipv":
+			if v, ok := integer(i); ok && v > 0 {
+				result.MultiPV, found = v, true
+				i++
+			}
+		case "score":
+			if i+1 < len(args) && strings.ToLower(args[i+1]) == "mate" {
+				if v, ok := integer(i + 1); ok && v != 0 {
+					result.Mate, result.HasScore, found = v, true, true
+					i += 2
+				}
+			} else if v, ok := integer(i); ok {
+				result.Score, result.HasScore, found = v, true, true
+				i++
+			}
+		case "pv":
+			// The rest of the arguments are the moves
+			result.PV = nil
+			for _, arg := range args[i+1:] {
+				move, err := strconv.Atoi(arg)
+				if err != nil {
+					break
+				}
+				result.PV = append(result.PV, move)
+			}
+			found = found || len(result.PV) != 0
+			i += len(result.PV)
+		}
+	}
+	return result, found
+}
+
+// Best is whether the info is about the engine's best move
+func (s SearchInfo) Best() bool {
+	return s.MultiPV <= 1
+}
+
+// Value is the score as a single number which can be compared
+// with other scores. Forced wins are given MateScore less the
+// number of moves until the win
+func (s SearchInfo) Value() int {
+	switch {
+	case s.Mate > 0:
+		return MateScore - s.Mate
+	case s.Mate < 0:
+		return -MateScore - s.Mate
+	}
+	return s.Score
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseLegacyCommand parses a command in the legacy text protocol
+// into the same requests as the JSON protocol. Commands are space
+// separated keywords followed by their values. Unknown commands
+// are ignored, in which case the request is nil
+func parseLegacyCommand(command string) (interface{}, error) {
+	// Seperate the command into its arguments
+	args := strings.Split(command, " ")
+	// Figure out which type of command has been received
+	switch strings.ToLower(args[0]) {
+	case "init":
+		return InitRequest{}, nil
+	case "newgame":
+		return NewGameRequest{}, nil
+	case "setplayers":
+		return parseLegacySetPlayers(args[1:])
+	case "play":
+		return PlayRequest{}, nil
+	case "pause":
+		return PauseRequest{}, nil
+	case "enginepaths":
+		return EnginePathsRequest{}, nil
+	case "engine":
+		return parseLegacyEngine(args[1:])
+	case "options":
+		return parseLegacyOptions(args[1:])
+	case "setoption":
+		return parseLegacySetOption(args[1:])
+	}
+	return nil, nil
+}
+
+// legacyValue finds the value that follows a keyword in args
+// The value ends at the next keyword in next, or the end of args
+func legacyValue(args []string, keyword string, next ...string) (string, bool) {
+	// Find the index of the keyword in args
+	index := SliceIndex(len(args), func(i int) bool {
+		return strings.ToLower(args[i]) == keyword
+	})
+	if index == -1 {
+		return "", false
+	}
+	// Find the end of the value
+	end := SliceIndex(len(args), func(i int) bool {
+		if i <= index {
+			return false
+		}
+		for _, n := range next {
+			if strings.ToLower(args[i]) == n {
+				return true
+			}
+		}
+		return false
+	})
+	if end == -1 {
+		end = len(args)
+	}
+	return strings.Join(args[index+1:end], " "), true
+}
+
+// legacyInt finds an integer that follows a keyword in args
+func legacyInt(args []string, keyword string, next ...string) (int, error) {
+	value, ok := legacyValue(args, keyword, next...)
+	if !ok {
+		return 0, errors.Errorf("couldn't find %s in command string", keyword)
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't convert %s into integer", keyword)
+	}
+	return result, nil
+}
+
+// parseLegacySetPlayers parses the arguments of a setplayers command
+func parseLegacySetPlayers(args []string) (interface{}, error) {
+	player1, err := legacyInt(args, "player1", "player2")
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get player1")
+	}
+	player2, err := legacyInt(args, "player2")
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get player2")
+	}
+	return SetPlayersRequest{Player1: player1, Player2: player2}, nil
+}
+
+// parseLegacyEngine parses the arguments of an engine command
+func parseLegacyEngine(args []string) (interface{}, error) {
+	// If there are no arguments, forget about it
+	if len(args) == 0 {
+		return nil, nil
+	}
+	switch strings.ToLower(args[0]) {
+	case "load":
+		path, ok := legacyValue(args[1:], "path")
+		if !ok {
+			return nil, errors.New("couldn't find path in command string")
+		}
+		return LoadEngineRequest{Path: path}, nil
+	case "unload":
+		id, err := legacyInt(args[1:], "id")
+		if err != nil {
+			return nil, err
+		}
+		return UnloadEngineRequest{ID: id}, nil
+	case "restart":
+		id, err := legacyInt(args[1:], "id", "enable")
+		if err != nil {
+			return nil, err
+		}
+		enable, ok := legacyValue(args[1:], "enable")
+		if !ok {
+			return nil, errors.New("couldn't find enable in command string")
+		}
+		return SetRestartRequest{ID: id, Enabled: enable == "true"}, nil
+	case "ponder":
+		id, err := legacyInt(args[1:], "id", "enable")
+		if err != nil {
+			return nil, err
+		}
+		enable, ok := legacyValue(args[1:], "enable")
+		if !ok {
+			return nil, errors.New("couldn't find enable in command string")
+		}
+		return SetPonderRequest{ID: id, Enabled: enable == "true"}, nil
+	case "saveprofile":
+		id, err := legacyInt(args[1:], "id", "name")
+		if err != nil {
+			return nil, err
+		}
+		name, ok := legacyValue(args[1:], "name")
+		if !ok {
+			return nil, errors.New("couldn't find name in command string")
+		}
+		return SaveProfileRequest{ID: id, Name: name}, nil
+	}
+	return nil, nil
+}
+
+// parseLegacyOptions parses the arguments of an options command
+func parseLegacyOptions(args []string) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New("couldn't find engineid in command string")
+	}
+	engineID, err := strconv.Atoi(args[len(args)-1])
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't aquire engine id")
+	}
+	return OptionsRequest{EngineID: engineID}, nil
+}
+
+// parseLegacySetOption parses the arguments of a setoption command
+// The value is optional as buttons don't have one
+func parseLegacySetOption(args []string) (interface{}, error) {
+	engineID, err := legacyInt(args, "engineid", "name")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid engineid")
+	}
+	name, ok := legacyValue(args, "name", "value")
+	if !ok {
+		return nil, errors.New("couldn't find name in command string")
+	}
+	value, _ := legacyValue(args, "value")
+	return SetOptionRequest{EngineID: engineID, Name: name, Value: value}, nil
+}
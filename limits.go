@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// limitPollInterval is how often an engine's process is checked
+	// to see if it has exceeded its resource limits
+	limitPollInterval = 100 * time.Millisecond
+	// maxCPU is the highest core index that an engine can be pinned to
+	maxCPU = 1023
+)
+
+// Names of the resource limits that can be exceeded
+// These are used as the reason an engine's process was killed
+const (
+	LimitMemory   = "memory"
+	LimitThreads  = "threads"
+	LimitLifetime = "lifetime"
+)
+
+// ResourceLimits restricts the resources that an engine's process
+// is allowed to use. Zero values mean that there is no limit
+type ResourceLimits struct {
+	// Memory is the maximum resident memory in megabytes
+	Memory int `json:"memory,omitempty"`
+	// CPUs are the indexes of the cores the process is pinned to
+	CPUs []int `json:"cpus,omitempty"`
+	// Nice is the niceness that the process runs with
+	Nice int `json:"nice,omitempty"`
+	// Threads is the maximum number of threads the process can have
+	Threads int `json:"threads,omitempty"`
+	// Lifetime is the maximum wall-clock time the process can run for
+	Lifetime Duration `json:"lifetime,omitempty"`
+}
+
+// Validate returns an error if any of the limits are impossible
+func (l ResourceLimits) Validate() error {
+	if l.Memory < 0 {
+		return errors.New("memory limit can't be negative")
+	}
+	if l.Threads < 0 {
+		return errors.New("thread limit can't be negative")
+	}
+	if l.Lifetime < 0 {
+		return errors.New("lifetime can't be negative")
+	}
+	if l.Nice < -20 || l.Nice > 19 {
+		return errors.New("niceness must be between -20 and 19")
+	}
+	for _, cpu := range l.CPUs {
+		if cpu < 0 || cpu > maxCPU {
+			return fmt.Errorf("cpu %d is out of range", cpu)
+		}
+	}
+	return nil
+}
+
+// watched returns true if the process needs to be
+// watched for as long as it is running
+func (l ResourceLimits) watched() bool {
+	return l.Memory > 0 || l.Threads > 0 || l.Lifetime > 0 ||
+		len(l.CPUs) > 0 || l.Nice != 0
+}
+
+// enforceLimits watches an engine's process until it exits.
+// If the process exceeds any of its limits, it is killed and
+// the limit is recorded as the reason. Memory and threads aren't
+// capped with rlimits, as an engine which ran into one would only
+// fail an allocation and look like it crashed. Core pinning and
+// niceness are reapplied on each check to catch any new threads
+func (e *Engine) enforceLimits(pid int, limits ResourceLimits, exited <-chan struct{}) {
+	if !limits.watched() {
+		return
+	}
+	var lifetime <-chan time.Time
+	if limits.Lifetime > 0 {
+		lifetime = time.After(time.Duration(limits.Lifetime))
+	}
+	ticker := time.NewTicker(limitPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			return
+		case <-lifetime:
+			e.breach(LimitLifetime)
+			return
+		case <-ticker.C:
+		}
+		// Checking the exit again as the pid may have been reused
+		select {
+		case <-exited:
+			return
+		default:
+		}
+		applyLimits(pid, limits)
+		usage, err := processUsage(pid)
+		if err != nil {
+			continue
+		}
+		if limits.Memory > 0 && usage.memory > int64(limits.Memory)*1024*1024 {
+			e.breach(LimitMemory)
+			return
+		}
+		if limits.Threads > 0 && usage.threads > limits.Threads {
+			e.breach(LimitThreads)
+			return
+		}
+	}
+}
+
+// breach kills the engine's process because it has exceeded a limit
+func (e *Engine) breach(limit string) {
+	e.lock.Lock()
+	e.breached = limit
+	e.lock.Unlock()
+	e.kill()
+}
+
+// resourceUsage is a snapshot of the resources a process is using
+type resourceUsage struct {
+	// memory is the resident memory in bytes
+	memory int64
+	// threads is the number of threads
+	threads int
+}
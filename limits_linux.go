@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// applyLimits pins every thread of a process to its cores and sets
+// their niceness. On Linux both of these are per thread, so each
+// thread listed in /proc is updated
+func applyLimits(pid int, limits ResourceLimits) error {
+	if len(limits.CPUs) == 0 && limits.Nice == 0 {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return errors.Wrap(err, "couldn't list threads")
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if len(limits.CPUs) > 0 {
+			if err := setAffinity(tid, limits.CPUs); err != nil {
+				return errors.Wrap(err, "couldn't set cpu affinity")
+			}
+		}
+		if limits.Nice != 0 {
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, limits.Nice); err != nil {
+				return errors.Wrap(err, "couldn't set niceness")
+			}
+		}
+	}
+	return nil
+}
+
+// setAffinity pins a thread to a set of cores
+func setAffinity(tid int, cpus []int) error {
+	var mask [(maxCPU + 1) / 64]uint64
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+	_, _, errno := syscall.RawSyscall(
+		syscall.SYS_SCHED_SETAFFINITY,
+		uintptr(tid),
+		uintptr(len(mask)*8),
+		uintptr(unsafe.Pointer(&mask[0])),
+	)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// processUsage reads the resident memory and thread
+// count of a process from /proc
+func processUsage(pid int) (resourceUsage, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return resourceUsage{}, errors.Wrap(err, "couldn't read process status")
+	}
+	defer file.Close()
+	result := resourceUsage{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "VmRSS:":
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return resourceUsage{}, errors.Wrap(err, "invalid resident memory")
+			}
+			result.memory = kilobytes * 1024
+		case "Threads:":
+			threads, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return resourceUsage{}, errors.Wrap(err, "invalid thread count")
+			}
+			result.threads = threads
+		}
+	}
+	return result, nil
+}
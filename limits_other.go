@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "github.com/pkg/errors"
+
+// applyLimits returns an error if any limits other than the
+// lifetime are set as they are only supported on Linux
+func applyLimits(pid int, limits ResourceLimits) error {
+	if len(limits.CPUs) > 0 || limits.Nice != 0 || limits.Memory > 0 || limits.Threads > 0 {
+		return errors.New("only the lifetime limit is supported on this platform")
+	}
+	return nil
+}
+
+// processUsage is only supported on Linux
+func processUsage(pid int) (resourceUsage, error) {
+	return resourceUsage{}, errors.New("resource usage is only supported on Linux")
+}
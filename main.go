@@ -1,9 +1,34 @@
 package main
 
-import "log"
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+// commands are the commands which can be run instead of
+// the user interface, e.g. konnect4 match -engine1 a -engine2 b
+// Each command is given its arguments and returns the exit code
+var commands = map[string]func(args []string) int{
+	"match":        runMatch,
+	"check-engine": runCheckEngine,
+	"worker":       runWorker,
+}
 
 func main() {
-	d, err := NewDevelop()
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+	config, err := LoadConfig(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	config.UseDirectories()
+	d, err := NewDevelop(config)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ResultHistorySize is the maximum number of game
+	// results which are kept by Develop
+	ResultHistorySize = 1000
+)
+
+// Match is a series of games between two engines. The engines
+// swap sides after every game so that neither of them has
+// the advantage of moving first more often
+type Match struct {
+	// Engine1 plays first in the first game
+	Engine1 *Engine
+	// Engine2 plays second in the first game
+	Engine2 *Engine
+	// Games is the number of games to be played
+	Games int
+	// TurnTime is the amount of time players are
+	// given to analyse each position
+	TurnTime time.Duration
+	// TimeControl, if it isn't nil, is used instead of TurnTime
+	TimeControl *TimeControl
+	// Openings are the positions games start from. Each opening
+	// is played twice so that both engines play each side of it.
+	// If there are none, games start from the starting position
+	Openings []State
+	// Adjudication, if it isn't nil, ends games
+	// early once their result is clear
+	Adjudication *Adjudication
+
+	lock    sync.Mutex
+	results []GameResult
+	running bool
+	err     error
+	// stop is closed when the match should stop
+	// after the game which is being played
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// resultChannel is where the result of each game is sent
+	resultChannel chan<- GameResult
+}
+
+// GameResult is the outcome of a finished game
+type GameResult struct {
+	// Time is when the game finished
+	Time time.Time `json:"time"`
+	// Player1 and Player2 are the names of the engines
+	Player1 string `json:"player1"`
+	Player2 string `json:"player2"`
+	// Winner is Player1, Player2 or Tie
+	Winner int `json:"winner"`
+	// Moves is the number of moves that were played
+	Moves int `json:"moves"`
+	// Reason is why the game finished and
+	// Detail describes the reason further
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+	// Times are the total time each player took
+	Times [2]Duration `json:"times"`
+	// History is every position of the game in CFP
+	History []string `json:"history"`
+}
+
+// MatchSummary is the progress and score of a match
+type MatchSummary struct {
+	Engine1 string `json:"engine1"`
+	Engine2 string `json:"engine2"`
+	Games   int    `json:"games"`
+	Played  int    `json:"played"`
+	// Wins1 and Wins2 are the number of games won
+	// by Engine1 and Engine2 respectively
+	Wins1   int  `json:"wins1"`
+	Wins2   int  `json:"wins2"`
+	Draws   int  `json:"draws"`
+	Running bool `json:"running"`
+	// Reasons is the number of games which
+	// finished for each of the reasons
+	Reasons map[string]int `json:"reasons"`
+	// Error is why the match ended early, if it did
+	Error   string       `json:"error,omitempty"`
+	Results []GameResult `json:"results"`
+}
+
+// NewMatch creates a match of games between two engines
+func NewMatch(engine1, engine2 *Engine, games int, turnTime time.Duration) (*Match, error) {
+	if engine1 == nil || engine2 == nil {
+		return nil, errors.New("match needs two engines")
+	}
+	if games <= 0 {
+		return nil, errors.New("number of games must be positive")
+	}
+	if turnTime <= 0 {
+		return nil, errors.New("turn time must be positive")
+	}
+	return &Match{
+		Engine1:  engine1,
+		Engine2:  engine2,
+		Games:    games,
+		TurnTime: turnTime,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// NotifyResults sets the channel in which the result
+// of each game is sent to as soon as it finishes
+func (m *Match) NotifyResults(channel chan<- GameResult) {
+	m.resultChannel = channel
+}
+
+// Play plays every game of the match, returning once the match is
+// over. An error is returned if a game couldn't be finished
+func (m *Match) Play() error {
+	done, err := m.Start()
+	if err != nil {
+		return err
+	}
+	<-done
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.err
+}
+
+// Start starts playing the match in the background
+// The returned channel is closed once the match is over
+func (m *Match) Start() (<-chan struct{}, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.running {
+		return nil, errors.New("match is already being played")
+	}
+	m.running = true
+	done := make(chan struct{})
+	go func() {
+		err := m.play()
+		m.lock.Lock()
+		m.running = false
+		m.err = err
+		m.lock.Unlock()
+		close(done)
+	}()
+	return done, nil
+}
+
+// Running reports whether the match is being played
+func (m *Match) Running() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.running
+}
+
+// play plays each of the games in turn until they have
+// all been played or the match is stopped
+func (m *Match) play() error {
+	for i := 0; i < m.Games; i++ {
+		select {
+		case <-m.stop:
+			return errors.New("match was stopped")
+		default:
+		}
+		// The engines swap sides every game
+		player1, player2 := m.Engine1, m.Engine2
+		if i%2 == 1 {
+			player1, player2 = player2, player1
+		}
+		result, err := m.playGame(player1, player2, m.opening(i))
+		if err != nil {
+			return errors.Wrapf(err, "couldn't finish game %d", i+1)
+		}
+		m.lock.Lock()
+		m.results = append(m.results, result)
+		m.lock.Unlock()
+		if m.resultChannel != nil {
+			m.resultChannel <- result
+		}
+		// An engine which crashed and wasn't restarted
+		// can't play any more games
+		for _, e := range []*Engine{m.Engine1, m.Engine2} {
+			if e.HasExited() {
+				return errors.Errorf("engine %s %s", e.Name, e.LastExit())
+			}
+		}
+	}
+	return nil
+}
+
+// opening gets the position the game at index starts from
+func (m *Match) opening(index int) State {
+	return matchOpening(m.Openings, index)
+}
+
+// matchOpening gets the position the game at index of a match
+// starts from. Each opening is used for two games in a row
+func matchOpening(openings []State, index int) State {
+	if len(openings) == 0 {
+		return NewState()
+	}
+	return openings[(index/2)%len(openings)]
+}
+
+// playGame plays a single game to completion
+func (m *Match) playGame(player1, player2 *Engine, opening State) (GameResult, error) {
+	game := NewGame(m.TurnTime)
+	game.TimeControl = m.TimeControl
+	game.Adjudication = m.Adjudication
+	if err := game.Position(opening); err != nil {
+		return GameResult{}, err
+	}
+	events := make(chan GameEvent)
+	game.NotifyEvents(events)
+	if err := game.SetPlayer1(player1); err != nil {
+		return GameResult{}, err
+	}
+	if err := game.SetPlayer2(player2); err != nil {
+		return GameResult{}, err
+	}
+	if err := game.Play(); err != nil {
+		return GameResult{}, err
+	}
+	for evt := range events {
+		switch v := evt.(type) {
+		case GameOverEvent:
+			return NewGameResult(game), nil
+		case ErrorEvent:
+			return GameResult{}, v.Error
+		}
+	}
+	return GameResult{}, errors.New("game ended unexpectedly")
+}
+
+// Stop stops the match once the game which
+// is being played has finished
+func (m *Match) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Summary gets the progress of the match so far
+func (m *Match) Summary() MatchSummary {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return newMatchSummary(m.Engine1.Name, m.Engine2.Name, m.Games, m.results, m.running, m.err)
+}
+
+// newMatchSummary summarises a match from
+// the results of the games played so far
+func newMatchSummary(engine1, engine2 string, games int, results []GameResult, running bool, err error) MatchSummary {
+	result := MatchSummary{
+		Engine1: engine1,
+		Engine2: engine2,
+		Games:   games,
+		Played:  len(results),
+		Running: running,
+		Results: append([]GameResult{}, results...),
+		Reasons: make(map[string]int),
+	}
+	for _, r := range results {
+		result.Reasons[r.Reason]++
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.Wins1, result.Wins2, result.Draws = MatchScore(results)
+	return result
+}
+
+// MatchScore counts the number of games won by each engine of
+// a match, along with the number of draws, from the results of
+// the games in the order they were played
+func MatchScore(results []GameResult) (int, int, int) {
+	var wins1, wins2, draws int
+	for i, r := range results {
+		// Engine1 is player1 in the even games
+		switch {
+		case r.Winner == Tie:
+			draws++
+		case (r.Winner == Player1) == (i%2 == 0):
+			wins1++
+		default:
+			wins2++
+		}
+	}
+	return wins1, wins2, draws
+}
+
+// NewGameResult gets the result of a game which has finished
+func NewGameResult(g *Game) GameResult {
+	record := g.Record()
+	result := GameResult{
+		Time:    time.Now(),
+		Winner:  record.Winner(),
+		Moves:   len(record.History) - 1,
+		History: make([]string, 0, len(record.History)),
+	}
+	if record.Outcome != nil {
+		result.Winner = record.Outcome.Winner
+		result.Reason = record.Outcome.Reason
+		result.Detail = record.Outcome.Detail
+	}
+	result.Times = [2]Duration{Duration(record.Times[0]), Duration(record.Times[1])}
+	if g.Player1 != nil {
+		result.Player1 = g.Player1.Name
+	}
+	if g.Player2 != nil {
+		result.Player2 = g.Player2.Name
+	}
+	for _, state := range record.History {
+		result.History = append(result.History, state.CFPString())
+	}
+	return result
+}
@@ -0,0 +1,773 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProtocolVersion is the version of the JSON WebSocket protocol
+	ProtocolVersion = 1
+)
+
+// Envelope is how every message in the JSON WebSocket protocol is
+// sent. Requests from clients can have an ID which is copied into
+// any response, allowing responses to be matched with requests
+type Envelope struct {
+	Version int             `json:"v"`
+	ID      int             `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Message is a message that the server sends to clients
+type Message interface {
+	// MessageType is the type of the message in the JSON protocol
+	MessageType() string
+	// LegacyCommands are the commands which represent the
+	// message in the legacy text protocol
+	LegacyCommands() []string
+}
+
+// encodeMessage encodes a message in the JSON protocol
+// id is the id of the request being responded to, 0 if
+// the message isn't a response
+func encodeMessage(id int, m Message) ([]byte, error) {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't encode payload")
+	}
+	return json.Marshal(Envelope{
+		Version: ProtocolVersion,
+		ID:      id,
+		Type:    m.MessageType(),
+		Payload: payload,
+	})
+}
+
+// decodeRequest decodes a request in the JSON protocol
+// The id of the request is returned along with the request
+func decodeRequest(data []byte) (int, interface{}, error) {
+	envelope := Envelope{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return 0, nil, errors.Wrap(err, "couldn't parse request")
+	}
+	if envelope.Version != ProtocolVersion {
+		return envelope.ID, nil, errors.Errorf("unsupported protocol version %d", envelope.Version)
+	}
+	var request interface{}
+	switch envelope.Type {
+	case "init":
+		request = &InitRequest{}
+	case "newgame":
+		request = &NewGameRequest{}
+	case "setplayers":
+		// Players which aren't given are left as they are
+		request = &SetPlayersRequest{Player1: -1, Player2: -1}
+	case "play":
+		request = &PlayRequest{}
+	case "pause":
+		request = &PauseRequest{}
+	case "enginepaths":
+		request = &EnginePathsRequest{}
+	case "loadengine":
+		request = &LoadEngineRequest{}
+	case "unloadengine":
+		request = &UnloadEngineRequest{}
+	case "setrestart":
+		request = &SetRestartRequest{}
+	case "setponder":
+		request = &SetPonderRequest{}
+	case "saveprofile":
+		request = &SaveProfileRequest{}
+	case "options":
+		request = &OptionsRequest{}
+	case "setoption":
+		request = &SetOptionRequest{}
+	case "engines":
+		request = &EnginesRequest{}
+	case "engine":
+		request = &EngineRequest{}
+	case "game":
+		request = &GameRequest{}
+	case "setposition":
+		request = &SetPositionRequest{}
+	case "setlimits":
+		request = &SetLimitsRequest{}
+	case "results":
+		request = &ResultsRequest{}
+	case "matches":
+		request = &MatchesRequest{}
+	case "match":
+		request = &MatchRequest{}
+	case "startmatch":
+		request = &StartMatchRequest{}
+	case "stopmatch":
+		request = &StopMatchRequest{}
+	default:
+		return envelope.ID, nil, errors.Errorf("unknown request type %q", envelope.Type)
+	}
+	if len(envelope.Payload) > 0 {
+		if err := json.Unmarshal(envelope.Payload, request); err != nil {
+			return envelope.ID, nil, errors.Wrapf(err, "couldn't parse %s payload", envelope.Type)
+		}
+	}
+	return envelope.ID, reflect.ValueOf(request).Elem().Interface(), nil
+}
+
+// InitRequest asks for the current state of everything
+type InitRequest struct{}
+
+// NewGameRequest asks for the game to be reset
+type NewGameRequest struct{}
+
+// SetPlayersRequest asks for the engines playing the game to
+// be changed. -1 leaves a player as it is
+type SetPlayersRequest struct {
+	Player1 int `json:"player1"`
+	Player2 int `json:"player2"`
+}
+
+// PlayRequest asks for the game to start being played
+type PlayRequest struct{}
+
+// PauseRequest asks for the game to stop being played
+type PauseRequest struct{}
+
+// EnginePathsRequest asks for the engines which can be loaded
+type EnginePathsRequest struct{}
+
+// LoadEngineRequest asks for an engine to be loaded
+type LoadEngineRequest struct {
+	// Path is RELATIVE to EngineDirectory
+	Path string `json:"path"`
+}
+
+// UnloadEngineRequest asks for an engine to be unloaded
+type UnloadEngineRequest struct {
+	ID int `json:"id"`
+}
+
+// SetRestartRequest asks for an engine's restart policy to be changed
+type SetRestartRequest struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
+// SetPonderRequest asks for an engine to start or stop
+// pondering in games
+type SetPonderRequest struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
+// SaveProfileRequest asks for an engine to be saved as a profile
+type SaveProfileRequest struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// OptionsRequest asks for the options of an engine
+type OptionsRequest struct {
+	EngineID int `json:"engineid"`
+}
+
+// SetOptionRequest asks for an option of an engine to be set
+// Value is ignored for buttons
+type SetOptionRequest struct {
+	EngineID int    `json:"engineid"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+}
+
+// EnginesRequest asks for a description of every loaded engine
+type EnginesRequest struct{}
+
+// EngineRequest asks for a description of a loaded engine
+type EngineRequest struct {
+	ID int `json:"id"`
+}
+
+// GameRequest asks for the state of the game
+type GameRequest struct{}
+
+// SetPositionRequest asks for the game to be set to a position
+type SetPositionRequest struct {
+	// Position is the CFP representation of the position
+	Position string `json:"position"`
+}
+
+// SetLimitsRequest asks for the players' searches to be limited
+type SetLimitsRequest struct {
+	SearchLimits
+}
+
+// ResultsRequest asks for the results of the finished games
+type ResultsRequest struct{}
+
+// MatchesRequest asks for a summary of every match
+type MatchesRequest struct{}
+
+// MatchRequest asks for a summary of a match
+type MatchRequest struct {
+	ID int `json:"id"`
+}
+
+// StartMatchRequest asks for a match to be played between two
+// engines. TurnTime defaults to the game's turn time
+type StartMatchRequest struct {
+	Engine1  int      `json:"engine1"`
+	Engine2  int      `json:"engine2"`
+	Games    int      `json:"games"`
+	TurnTime Duration `json:"turntime,omitempty"`
+	// Adjudication, if it's given, ends games
+	// early once their result is clear
+	Adjudication *Adjudication `json:"adjudication,omitempty"`
+}
+
+// StopMatchRequest asks for a match to stop after the
+// game which is being played
+type StopMatchRequest struct {
+	ID int `json:"id"`
+}
+
+// OkMessage is the response to a request which
+// succeeded and has nothing else to respond with
+type OkMessage struct{}
+
+// MessageType implements Message
+func (OkMessage) MessageType() string { return "ok" }
+
+// LegacyCommands implements Message
+func (OkMessage) LegacyCommands() []string { return nil }
+
+// ErrorMessage is the response to a request which failed
+type ErrorMessage struct {
+	Message string `json:"message"`
+}
+
+// MessageType implements Message
+func (ErrorMessage) MessageType() string { return "error" }
+
+// LegacyCommands implements Message
+func (m ErrorMessage) LegacyCommands() []string {
+	return OutputMessage{Time: time.Now(), Sender: "ERROR", Message: m.Message}.LegacyCommands()
+}
+
+// EngineLoadMessage is sent when an engine has been loaded
+type EngineLoadMessage struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+}
+
+// MessageType implements Message
+func (EngineLoadMessage) MessageType() string { return "engineload" }
+
+// LegacyCommands implements Message
+func (m EngineLoadMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("engine load id %d name %s author %s", m.ID, m.Name, m.Author)}
+}
+
+// EngineUnloadMessage is sent when an engine has been unloaded
+type EngineUnloadMessage struct {
+	ID int `json:"id"`
+}
+
+// MessageType implements Message
+func (EngineUnloadMessage) MessageType() string { return "engineunload" }
+
+// LegacyCommands implements Message
+func (m EngineUnloadMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("engine unload id %d", m.ID)}
+}
+
+// EngineCrashesMessage is sent when the number of
+// times an engine has crashed changes
+type EngineCrashesMessage struct {
+	ID    int `json:"id"`
+	Count int `json:"count"`
+}
+
+// MessageType implements Message
+func (EngineCrashesMessage) MessageType() string { return "enginecrashes" }
+
+// LegacyCommands implements Message
+func (m EngineCrashesMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("engine crashes id %d count %d", m.ID, m.Count)}
+}
+
+// EngineRestartMessage is sent when an engine's restart policy changes
+type EngineRestartMessage struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
+// MessageType implements Message
+func (EngineRestartMessage) MessageType() string { return "enginerestart" }
+
+// LegacyCommands implements Message
+func (m EngineRestartMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("engine restart id %d enabled %t", m.ID, m.Enabled)}
+}
+
+// EnginePonderMessage is sent when an engine starts or
+// stops pondering in games
+type EnginePonderMessage struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
+// MessageType implements Message
+func (EnginePonderMessage) MessageType() string { return "engineponder" }
+
+// LegacyCommands implements Message
+func (m EnginePonderMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("engine ponder id %d enabled %t", m.ID, m.Enabled)}
+}
+
+// EngineDescription describes a loaded engine
+type EngineDescription struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Author string `json:"author"`
+	// Path is the path the engine was loaded from,
+	// RELATIVE to EngineDirectory
+	Path    string `json:"path"`
+	Crashes int    `json:"crashes"`
+	Restart bool   `json:"restart"`
+	Ponder  bool   `json:"ponder"`
+}
+
+// EngineMessage is the response to an EngineRequest
+// or LoadEngineRequest
+type EngineMessage struct {
+	EngineDescription
+}
+
+// MessageType implements Message
+func (EngineMessage) MessageType() string { return "engine" }
+
+// LegacyCommands implements Message
+func (EngineMessage) LegacyCommands() []string { return nil }
+
+// EnginesMessage is the response to an EnginesRequest
+type EnginesMessage struct {
+	Engines []EngineDescription `json:"engines"`
+}
+
+// MessageType implements Message
+func (EnginesMessage) MessageType() string { return "engines" }
+
+// LegacyCommands implements Message
+func (EnginesMessage) LegacyCommands() []string { return nil }
+
+// EnginePathsMessage is the response to an EnginePathsRequest
+type EnginePathsMessage struct {
+	Paths []string `json:"paths"`
+}
+
+// MessageType implements Message
+func (EnginePathsMessage) MessageType() string { return "enginepaths" }
+
+// LegacyCommands implements Message
+func (m EnginePathsMessage) LegacyCommands() []string {
+	if len(m.Paths) == 0 {
+		return []string{"noenginepaths"}
+	}
+	return []string{"enginepaths path " + strings.Join(m.Paths, " path ")}
+}
+
+// OptionsMessage is the response to an OptionsRequest
+type OptionsMessage struct {
+	EngineID int                 `json:"engineid"`
+	Options  []OptionDescription `json:"options"`
+}
+
+// OptionDescription fully describes an Option
+type OptionDescription struct {
+	Name string `json:"name"`
+	// Type is one of check, spin, combo, button or string
+	Type string `json:"type"`
+	// Value is a bool, int or string depending on the type
+	// Buttons don't have a value
+	Value interface{} `json:"value,omitempty"`
+	// Min and Max are only used by spins
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+	// Vars are the possible values of a combo
+	Vars []string `json:"vars,omitempty"`
+}
+
+// DescribeOption returns an OptionDescription for an Option
+func DescribeOption(o Option) (OptionDescription, error) {
+	result := OptionDescription{Name: o.OptionName()}
+	switch v := o.(type) {
+	case CheckBox:
+		result.Type = "check"
+		result.Value = v.Value
+	case Spinner:
+		result.Type = "spin"
+		result.Value = v.Value
+		result.Min = v.Min
+		result.Max = v.Max
+	case ComboBox:
+		result.Type = "combo"
+		result.Value = v.Value
+		for k := range v.Vars {
+			result.Vars = append(result.Vars, k)
+		}
+		sort.Strings(result.Vars)
+	case Button:
+		result.Type = "button"
+	case String:
+		result.Type = "string"
+		result.Value = v.Value
+	default:
+		return OptionDescription{}, errors.New("option type not supported")
+	}
+	return result, nil
+}
+
+// MessageType implements Message
+func (OptionsMessage) MessageType() string { return "options" }
+
+// LegacyCommands implements Message
+func (m OptionsMessage) LegacyCommands() []string {
+	if len(m.Options) == 0 {
+		return []string{"nooptions"}
+	}
+	result := make([]string, 0, len(m.Options))
+	for _, o := range m.Options {
+		var description string
+		switch o.Type {
+		case "check":
+			description = fmt.Sprintf("type check value %t", o.Value)
+		case "spin":
+			description = fmt.Sprintf("type spin min %d max %d value %d", o.Min, o.Max, o.Value)
+		case "combo":
+			description = fmt.Sprintf("type combo value %s var %s", o.Value, strings.Join(o.Vars, " var "))
+		case "button":
+			description = "type button"
+		case "string":
+			description = fmt.Sprintf("type string value %s", o.Value)
+		}
+		result = append(result, fmt.Sprintf(
+			"option engineid %d name %s %s", m.EngineID, o.Name, description,
+		))
+	}
+	return result
+}
+
+// UpdateOptionMessage is sent when an option of an engine is set
+type UpdateOptionMessage struct {
+	EngineID int    `json:"engineid"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+}
+
+// MessageType implements Message
+func (UpdateOptionMessage) MessageType() string { return "updateoption" }
+
+// LegacyCommands implements Message
+func (m UpdateOptionMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf(
+		"updateoption engineid %d name %s value %s", m.EngineID, m.Name, m.Value,
+	)}
+}
+
+// PlayersMessage is sent when the players of the game change
+// -1 means that a player isn't set
+type PlayersMessage struct {
+	Player1 int `json:"player1"`
+	Player2 int `json:"player2"`
+}
+
+// MessageType implements Message
+func (PlayersMessage) MessageType() string { return "players" }
+
+// LegacyCommands implements Message
+func (m PlayersMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("players player1 %d player2 %d", m.Player1, m.Player2)}
+}
+
+// LimitsMessage is sent when the limits of
+// the players' searches change
+type LimitsMessage struct {
+	SearchLimits
+}
+
+// MessageType implements Message
+func (LimitsMessage) MessageType() string { return "limits" }
+
+// LegacyCommands implements Message
+func (LimitsMessage) LegacyCommands() []string { return nil }
+
+// NewGameMessage is sent when a new game starts
+// It's followed by the starting position
+type NewGameMessage struct{}
+
+// MessageType implements Message
+func (NewGameMessage) MessageType() string { return "newgame" }
+
+// LegacyCommands implements Message
+func (NewGameMessage) LegacyCommands() []string { return []string{"newgame"} }
+
+// PositionMessage is sent when a new position is reached
+type PositionMessage struct {
+	// Position is the CFP representation of the position
+	Position string `json:"position"`
+	// Search is how the player found the move which reached
+	// the position. It's nil for the game's first position
+	Search *SearchMessage `json:"search,omitempty"`
+}
+
+// SearchMessage is what a player reported about its search for
+// a move and the time it took. Scores are from player1's point
+// of view, with forced wins given MateScore less their moves
+type SearchMessage struct {
+	Depth    int      `json:"depth"`
+	HasScore bool     `json:"hasscore"`
+	Score    int      `json:"score"`
+	Time     Duration `json:"time"`
+}
+
+// NewSearchMessage creates the message for a player's search
+func NewSearchMessage(s MoveSearch) SearchMessage {
+	return SearchMessage{
+		Depth:    s.Depth,
+		HasScore: s.HasScore,
+		Score:    s.Score,
+		Time:     Duration(s.Time),
+	}
+}
+
+// MoveSearch converts the message back to a player's search
+func (m SearchMessage) MoveSearch() MoveSearch {
+	return MoveSearch{
+		Depth:    m.Depth,
+		HasScore: m.HasScore,
+		Score:    m.Score,
+		Time:     time.Duration(m.Time),
+	}
+}
+
+// MessageType implements Message
+func (PositionMessage) MessageType() string { return "position" }
+
+// LegacyCommands implements Message
+func (m PositionMessage) LegacyCommands() []string {
+	return []string{"position " + m.Position}
+}
+
+// PlayMessage is sent when the game starts being played
+type PlayMessage struct{}
+
+// MessageType implements Message
+func (PlayMessage) MessageType() string { return "play" }
+
+// LegacyCommands implements Message
+func (PlayMessage) LegacyCommands() []string { return []string{"play"} }
+
+// PauseMessage is sent when the game is paused
+type PauseMessage struct{}
+
+// MessageType implements Message
+func (PauseMessage) MessageType() string { return "pause" }
+
+// LegacyCommands implements Message
+func (PauseMessage) LegacyCommands() []string { return []string{"pause"} }
+
+// GameOverMessage is sent when the game finishes
+type GameOverMessage struct {
+	// Winner is Player1, Player2, Tie or Empty
+	// if the game was aborted without a result
+	Winner int    `json:"winner"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+	// Line is the indices of the four connected
+	// tiles when the game was won by connecting four
+	Line  []int `json:"line,omitempty"`
+	Moves int   `json:"moves"`
+	// Times are the total time each player took
+	Times [2]Duration `json:"times"`
+}
+
+// NewGameOverMessage creates the message for a game which has finished
+func NewGameOverMessage(e GameOverEvent) GameOverMessage {
+	return GameOverMessage{
+		Winner: e.Winner,
+		Reason: e.Reason,
+		Detail: e.Detail,
+		Line:   e.Line,
+		Moves:  e.Moves,
+		Times:  [2]Duration{Duration(e.Times[0]), Duration(e.Times[1])},
+	}
+}
+
+// MessageType implements Message
+func (GameOverMessage) MessageType() string { return "gameover" }
+
+// LegacyCommands implements Message
+func (m GameOverMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf("gameover winner %d", m.Winner)}
+}
+
+// GameMessage is the response to a GameRequest
+type GameMessage struct {
+	// Player1 and Player2 are the ids of the engines
+	// playing the game, -1 if they aren't set
+	Player1 int `json:"player1"`
+	Player2 int `json:"player2"`
+	// Position is the current position in CFP
+	Position string `json:"position"`
+	// History is every position of the game in CFP
+	History []string `json:"history"`
+	// Searches are how the players found each move
+	Searches []SearchMessage `json:"searches"`
+	Running  bool            `json:"running"`
+	Winner   int             `json:"winner"`
+	TurnTime Duration        `json:"turntime"`
+	// Limits restrict the players' searches
+	Limits SearchLimits `json:"limits"`
+	// Outcome is how the game finished, if it has
+	Outcome *GameOverMessage `json:"outcome,omitempty"`
+}
+
+// MessageType implements Message
+func (GameMessage) MessageType() string { return "game" }
+
+// LegacyCommands implements Message
+func (GameMessage) LegacyCommands() []string { return nil }
+
+// ResultsMessage is the response to a ResultsRequest
+type ResultsMessage struct {
+	Results []GameResult `json:"results"`
+}
+
+// MessageType implements Message
+func (ResultsMessage) MessageType() string { return "results" }
+
+// LegacyCommands implements Message
+func (ResultsMessage) LegacyCommands() []string { return nil }
+
+// MatchMessage is the response to a MatchRequest
+// or StartMatchRequest
+type MatchMessage struct {
+	ID int `json:"id"`
+	MatchSummary
+}
+
+// MessageType implements Message
+func (MatchMessage) MessageType() string { return "match" }
+
+// LegacyCommands implements Message
+func (MatchMessage) LegacyCommands() []string { return nil }
+
+// MatchesMessage is the response to a MatchesRequest
+type MatchesMessage struct {
+	Matches []MatchMessage `json:"matches"`
+}
+
+// MessageType implements Message
+func (MatchesMessage) MessageType() string { return "matches" }
+
+// LegacyCommands implements Message
+func (MatchesMessage) LegacyCommands() []string { return nil }
+
+// MessageType implements Message
+func (OutputMessage) MessageType() string { return "output" }
+
+// LegacyCommands implements Message
+func (m OutputMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf(
+		"output time %s sender %s message %s",
+		FormatTime(m.Time), m.Sender, m.Message,
+	)}
+}
+
+// CommunicationMessage is sent when a command is sent
+// to or received from an engine
+type CommunicationMessage struct {
+	Time     time.Time `json:"time"`
+	Engine   string    `json:"engine"`
+	ToEngine bool      `json:"toengine"`
+	Message  string    `json:"message"`
+}
+
+// MessageType implements Message
+func (CommunicationMessage) MessageType() string { return "communication" }
+
+// LegacyCommands implements Message
+func (m CommunicationMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf(
+		"communication time %s engine %s toengine %t message %s",
+		FormatTime(m.Time), m.Engine, m.ToEngine, m.Message,
+	)}
+}
+
+// AnalysisMessage is sent when an engine reports on one of the
+// moves it's searching. Scores are from the point of view of
+// the player to move and PV starts with the move
+type AnalysisMessage struct {
+	// ID is the id of the engine
+	ID int `json:"id"`
+	// MultiPV is the rank of the move, 1 being the best
+	MultiPV  int   `json:"multipv"`
+	Depth    int   `json:"depth"`
+	HasScore bool  `json:"hasscore"`
+	Score    int   `json:"score"`
+	Mate     int   `json:"mate,omitempty"`
+	Nodes    int   `json:"nodes"`
+	PV       []int `json:"pv"`
+}
+
+// NewAnalysisMessage creates an AnalysisMessage from an
+// engine's structured info
+func NewAnalysisMessage(id int, info SearchInfo) AnalysisMessage {
+	result := AnalysisMessage{
+		ID:       id,
+		MultiPV:  info.MultiPV,
+		Depth:    info.Depth,
+		HasScore: info.HasScore,
+		Score:    info.Score,
+		Mate:     info.Mate,
+		Nodes:    info.Nodes,
+		PV:       info.PV,
+	}
+	if result.MultiPV == 0 {
+		result.MultiPV = 1
+	}
+	return result
+}
+
+// MessageType implements Message
+func (AnalysisMessage) MessageType() string { return "analysis" }
+
+// LegacyCommands implements Message
+// The info is already sent to the output terminal
+func (AnalysisMessage) LegacyCommands() []string { return nil }
+
+// StderrMessage is sent when an engine writes a line to stderr
+type StderrMessage struct {
+	Time    time.Time `json:"time"`
+	Engine  string    `json:"engine"`
+	Message string    `json:"message"`
+}
+
+// MessageType implements Message
+func (StderrMessage) MessageType() string { return "stderr" }
+
+// LegacyCommands implements Message
+func (m StderrMessage) LegacyCommands() []string {
+	return []string{fmt.Sprintf(
+		"stderr time %s engine %s message %s",
+		FormatTime(m.Time), m.Engine, m.Message,
+	)}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LoadOpenings reads the starting positions of games from a file.
+// Each line is either a position in CFP or the columns of the moves
+// played from the starting position, e.g. 3324. Empty lines and
+// lines starting with # are ignored
+func LoadOpenings(path string) ([]State, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't open openings")
+	}
+	defer file.Close()
+	var result []State
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		opening, err := ParseOpening(text)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid opening on line %d", line)
+		}
+		result = append(result, opening)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "couldn't read openings")
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no openings found")
+	}
+	return result, nil
+}
+
+// ParseOpening parses a position in CFP or the columns of
+// the moves played from the starting position
+func ParseOpening(s string) (State, error) {
+	result := NewState()
+	if len(s) == 43 {
+		var err error
+		if result, err = StateFromCFP(s); err != nil {
+			return State{}, err
+		}
+	} else {
+		for _, c := range s {
+			if c < '0' || c > '6' {
+				return State{}, errors.Errorf("invalid move %q", c)
+			}
+			var err error
+			if result, err = result.NextState(int(c - '0')); err != nil {
+				return State{}, err
+			}
+		}
+	}
+	if result.Winner != Empty {
+		return State{}, errors.New("game is already over")
+	}
+	return result, nil
+}
@@ -0,0 +1,254 @@
+// Package alphabeta is an engine which searches with alpha-beta pruned
+// negamax and iterative deepening, evaluating positions by the fours
+// in a row each player can still make
+package alphabeta
+
+import (
+	"strconv"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+const (
+	// DepthOption is the name of the option for the deepest search
+	DepthOption = "Depth"
+	// MaxDepth is the deepest a search can be
+	// as there are only 42 moves in a game
+	MaxDepth = 42
+	// winScore is the score of a win, less the
+	// number of moves it takes to win
+	winScore = 1000000
+	// stopInterval is how many positions are
+	// searched between checks for a stop
+	stopInterval = 1024
+)
+
+// order is the order moves are searched in. Central
+// columns are tried first as they are usually the best
+var order = [7]int{3, 2, 4, 1, 5, 0, 6}
+
+// windowScores are how much a four in a row that only one
+// player has tiles in is worth to them, by the number of tiles
+var windowScores = [4]int{0, 1, 5, 25}
+
+// lines are the fours in a row which are possible
+var lines = connect4.Lines()
+
+// Player searches deeper and deeper until it's stopped
+// or it reaches its depth option or a limit
+type Player struct {
+	// Depth is the deepest the player searches
+	Depth int
+}
+
+// New creates an alpha-beta player which
+// searches until it's told to stop
+func New() *Player {
+	return &Player{Depth: MaxDepth}
+}
+
+// Name identifies the player
+func (p *Player) Name() string {
+	return "AlphaBeta"
+}
+
+// Author identifies the player
+func (p *Player) Author() string {
+	return "Konnect4"
+}
+
+// Options are the options the player has
+func (p *Player) Options() []player.Option {
+	return []player.Option{{
+		Name: DepthOption, Type: player.Spin,
+		Default: strconv.Itoa(MaxDepth), Min: 1, Max: MaxDepth,
+	}}
+}
+
+// SetOption changes one of the player's options
+func (p *Player) SetOption(name, value string) error {
+	if name == DepthOption {
+		p.Depth, _ = strconv.Atoi(value)
+	}
+	return nil
+}
+
+// Search searches the position one move deeper at a time,
+// reporting the result of each depth as it's completed
+func (p *Player) Search(s connect4.State, limits player.Limits) (int, player.Info) {
+	maxDepth := p.Depth
+	if limits.Depth > 0 && limits.Depth < maxDepth {
+		maxDepth = limits.Depth
+	}
+	if empty := 42 - s.Turn; maxDepth > empty {
+		maxDepth = empty
+	}
+	moves := ordered(limits.Moves(s))
+	search := search{limits: limits}
+	// Without finishing a depth the most central move is played
+	best := player.Info{PV: []int{moves[0]}}
+	for depth := 1; depth <= maxDepth; depth++ {
+		score, pv := search.root(s, moves, depth)
+		if search.stopped {
+			break
+		}
+		best = info(depth, score, search.nodes, pv)
+		if limits.Report != nil {
+			limits.Report(best)
+		}
+		// A forced result won't change with a deeper search
+		if score >= winScore-MaxDepth || score <= -winScore+MaxDepth {
+			break
+		}
+	}
+	best.Nodes = search.nodes
+	return best.PV[0], best
+}
+
+// info describes the result of searching to a depth
+func info(depth, score, nodes int, pv []int) player.Info {
+	result := player.Info{Depth: depth, HasScore: true, Score: score, Nodes: nodes, PV: pv}
+	// The number of moves until the win is counted
+	// in the moves of the player who is winning
+	switch {
+	case score >= winScore-MaxDepth:
+		result.Mate = (winScore - score + 1) / 2
+	case score <= -winScore+MaxDepth:
+		result.Mate = -(winScore + score + 1) / 2
+	}
+	return result
+}
+
+// search is the state of a single search
+type search struct {
+	limits player.Limits
+	nodes  int
+	// stopped is whether the search was stopped before
+	// it finished, in which case its result is unusable
+	stopped bool
+}
+
+// root searches the moves of the position to a depth
+// The moves are expected to be in the order they're searched in
+// The score is returned along with the principal variation
+func (s *search) root(state connect4.State, moves []int, depth int) (int, []int) {
+	alpha, beta := -winScore-1, winScore+1
+	var pv []int
+	for _, move := range moves {
+		next, err := state.NextState(move)
+		if err != nil {
+			continue
+		}
+		score, line := s.child(state, next, depth, 0, alpha, beta)
+		if s.stopped {
+			return 0, nil
+		}
+		if score > alpha || pv == nil {
+			alpha = score
+			pv = append([]int{move}, line...)
+		}
+	}
+	return alpha, pv
+}
+
+// negamax finds the score of a position for the player to move,
+// searching depth moves ahead. ply is how many moves have been
+// played since the root. Scores outside of alpha and beta are
+// only bounds. The moves expected to be played are also returned
+func (s *search) negamax(state connect4.State, depth, ply, alpha, beta int) (int, []int) {
+	s.nodes++
+	if s.nodes%stopInterval == 0 && s.limits.Stopped() {
+		s.stopped = true
+	}
+	if s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes {
+		s.stopped = true
+	}
+	if s.stopped {
+		return 0, nil
+	}
+	if depth == 0 {
+		return evaluate(state), nil
+	}
+	best := -winScore - 1
+	var pv []int
+	legal := state.LegalActions()
+	for _, move := range order {
+		if !legal[move] {
+			continue
+		}
+		next, err := state.NextState(move)
+		if err != nil {
+			continue
+		}
+		score, line := s.child(state, next, depth, ply, alpha, beta)
+		if s.stopped {
+			return 0, nil
+		}
+		if score > best {
+			best = score
+			pv = append([]int{move}, line...)
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best, pv
+}
+
+// child scores a move from state to next for the
+// player who made it, searching further if needed
+func (s *search) child(state, next connect4.State, depth, ply, alpha, beta int) (int, []int) {
+	switch next.Winner {
+	case state.Player:
+		// Winning sooner is better
+		return winScore - ply - 1, nil
+	case connect4.Tie:
+		return 0, nil
+	}
+	score, line := s.negamax(next, depth-1, ply+1, -beta, -alpha)
+	return -score, line
+}
+
+// evaluate scores a position for the player to move by the fours in
+// a row which only one of the players has tiles in, as the other
+// player can no longer complete them
+func evaluate(state connect4.State) int {
+	result := 0
+	for _, line := range lines {
+		mine, theirs := 0, 0
+		for _, i := range line {
+			switch state.Tiles[i] {
+			case state.Player:
+				mine++
+			case connect4.Empty:
+			default:
+				theirs++
+			}
+		}
+		switch {
+		case theirs == 0:
+			result += windowScores[mine]
+		case mine == 0:
+			result -= windowScores[theirs]
+		}
+	}
+	return result
+}
+
+// ordered sorts moves into the order they're searched in
+func ordered(moves []int) []int {
+	result := make([]int, 0, len(moves))
+	for _, move := range order {
+		for _, m := range moves {
+			if m == move {
+				result = append(result, move)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,151 @@
+package alphabeta
+
+import (
+	"testing"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// play gets the position after the moves
+// have been played from the start
+func play(t *testing.T, moves ...int) connect4.State {
+	s := connect4.NewState()
+	for _, move := range moves {
+		var err error
+		if s, err = s.NextState(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// contains is whether move is one of moves
+func contains(moves []int, move int) bool {
+	for _, m := range moves {
+		if m == move {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []int
+		// want are the moves which can be played
+		want []int
+		mate int
+	}{
+		{"win", []int{0, 1, 0, 1, 0, 5}, []int{0}, 1},
+		{"win rather than block", []int{0, 6, 0, 6, 0, 6}, []int{0}, 1},
+		{"block vertical", []int{0, 6, 0, 6, 0}, []int{0}, 0},
+		{"block horizontal", []int{0, 6, 1, 6, 2}, []int{3}, 0},
+	}
+	for _, test := range tests {
+		s := play(t, test.moves...)
+		p := New()
+		p.SetOption(DepthOption, "6")
+		move, info := p.Search(s, player.Limits{})
+		if !contains(test.want, move) {
+			t.Errorf("%s: got move %d, want one of %v", test.name, move, test.want)
+		}
+		if test.mate != 0 && info.Mate != test.mate {
+			t.Errorf("%s: got mate %d, want %d", test.name, info.Mate, test.mate)
+		}
+		if len(info.PV) == 0 || info.PV[0] != move {
+			t.Errorf("%s: got pv %v for move %d", test.name, info.PV, move)
+		}
+	}
+}
+
+func TestSearchDepth(t *testing.T) {
+	tests := []struct {
+		name   string
+		option string
+		limit  int
+		want   int
+	}{
+		{"option", "3", 0, 3},
+		{"limit", "5", 2, 2},
+		{"option below limit", "2", 4, 2},
+	}
+	for _, test := range tests {
+		p := New()
+		p.SetOption(DepthOption, test.option)
+		var reported []int
+		limits := player.Limits{
+			Depth:  test.limit,
+			Report: func(info player.Info) { reported = append(reported, info.Depth) },
+		}
+		_, info := p.Search(connect4.NewState(), limits)
+		if info.Depth != test.want {
+			t.Errorf("%s: searched to depth %d, want %d", test.name, info.Depth, test.want)
+		}
+		if len(reported) != test.want || reported[len(reported)-1] != test.want {
+			t.Errorf("%s: reported depths %v, want 1 to %d", test.name, reported, test.want)
+		}
+	}
+}
+
+func TestSearchNodes(t *testing.T) {
+	for _, nodes := range []int{1, 100, 5000} {
+		move, info := New().Search(connect4.NewState(), player.Limits{Nodes: nodes})
+		if info.Nodes > nodes {
+			t.Errorf("searched %d nodes, want at most %d", info.Nodes, nodes)
+		}
+		if move < 0 || move >= 7 {
+			t.Errorf("got move %d with %d nodes", move, nodes)
+		}
+	}
+}
+
+func TestSearchMoves(t *testing.T) {
+	// Column 0 wins but it isn't one of the moves to search
+	s := play(t, 0, 1, 0, 1, 0, 5)
+	searchMoves := []int{5, 6}
+	var reported []player.Info
+	limits := player.Limits{
+		SearchMoves: searchMoves,
+		Report:      func(info player.Info) { reported = append(reported, info) },
+	}
+	p := New()
+	p.SetOption(DepthOption, "4")
+	move, _ := p.Search(s, limits)
+	if !contains(searchMoves, move) {
+		t.Errorf("got move %d, want one of %v", move, searchMoves)
+	}
+	for _, info := range reported {
+		if len(info.PV) == 0 || !contains(searchMoves, info.PV[0]) {
+			t.Errorf("depth %d reported pv %v, want it to start with one of %v", info.Depth, info.PV, searchMoves)
+		}
+	}
+}
+
+func TestInfoMate(t *testing.T) {
+	tests := []struct {
+		name  string
+		score int
+		mate  int
+	}{
+		{"win on this move", winScore - 1, 1},
+		{"win on the next move", winScore - 3, 2},
+		{"win on the last move", winScore - MaxDepth + 1, 21},
+		{"loss on the opponent's move", -winScore + 2, -1},
+		{"loss on the opponent's next move", -winScore + 4, -2},
+		{"loss on the last move", -winScore + MaxDepth, -21},
+		{"ahead", 500, 0},
+		{"behind", -500, 0},
+		{"even", 0, 0},
+	}
+	for _, test := range tests {
+		result := info(1, test.score, 1, []int{3})
+		if result.Mate != test.mate {
+			t.Errorf("%s: got mate %d for score %d, want %d", test.name, result.Mate, test.score, test.mate)
+		}
+		if !result.HasScore || result.Score != test.score {
+			t.Errorf("%s: got score %d, want %d", test.name, result.Score, test.score)
+		}
+	}
+}
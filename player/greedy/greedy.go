@@ -0,0 +1,78 @@
+// Package greedy is an engine which only looks one move ahead. It wins
+// whenever it can and blocks its opponent from winning when it needs to
+package greedy
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// Player plays a winning move if there is one, otherwise it blocks
+// the opponent's winning move if they have one. Any other move is
+// random, so it can walk into a win for its opponent
+type Player struct {
+	rand *rand.Rand
+}
+
+// New creates a greedy player
+func New() *Player {
+	return &Player{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Name identifies the player
+func (p *Player) Name() string {
+	return "Greedy"
+}
+
+// Author identifies the player
+func (p *Player) Author() string {
+	return "Konnect4"
+}
+
+// Search picks a move straight away
+func (p *Player) Search(s connect4.State, limits player.Limits) (int, player.Info) {
+	moves := limits.Moves(s)
+	// Winning straight away
+	for _, move := range moves {
+		if next, err := s.NextState(move); err == nil && next.Winner == s.Player {
+			return move, player.Info{Depth: 1, HasScore: true, Mate: 1, Nodes: len(moves), PV: []int{move}}
+		}
+	}
+	// Blocking the opponent from winning on their next move
+	threats := wins(opponent(s))
+	for _, move := range moves {
+		if threats[move] {
+			return move, player.Info{Depth: 1, Nodes: len(moves), PV: []int{move}}
+		}
+	}
+	// Otherwise any move will do
+	move := moves[p.rand.Intn(len(moves))]
+	return move, player.Info{Depth: 1, Nodes: len(moves), PV: []int{move}}
+}
+
+// wins finds the columns the player to move would win by playing
+func wins(s connect4.State) [7]bool {
+	var result [7]bool
+	for move, legal := range s.LegalActions() {
+		if !legal {
+			continue
+		}
+		if next, err := s.NextState(move); err == nil && next.Winner == s.Player {
+			result[move] = true
+		}
+	}
+	return result
+}
+
+// opponent gets the position as if it were the opponent's move
+func opponent(s connect4.State) connect4.State {
+	if s.Player == connect4.Player1 {
+		s.Player = connect4.Player2
+	} else {
+		s.Player = connect4.Player1
+	}
+	return s
+}
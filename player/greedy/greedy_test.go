@@ -0,0 +1,50 @@
+package greedy
+
+import (
+	"testing"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// play gets the position after the moves
+// have been played from the start
+func play(t *testing.T, moves ...int) connect4.State {
+	s := connect4.NewState()
+	for _, move := range moves {
+		var err error
+		if s, err = s.NextState(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		moves []int
+		// want are the moves which can be played
+		want []int
+		mate int
+	}{
+		{"win", []int{0, 1, 0, 1, 0, 5}, []int{0}, 1},
+		{"win rather than block", []int{0, 6, 0, 6, 0, 6}, []int{0}, 1},
+		{"block vertical", []int{0, 6, 0, 6, 0}, []int{0}, 0},
+		{"block horizontal", []int{1, 1, 2, 2, 3}, []int{0, 4}, 0},
+	}
+	for _, test := range tests {
+		s := play(t, test.moves...)
+		move, info := New().Search(s, player.Limits{})
+		found := false
+		for _, want := range test.want {
+			found = found || move == want
+		}
+		if !found {
+			t.Errorf("%s: got move %d, want one of %v", test.name, move, test.want)
+		}
+		if info.Mate != test.mate {
+			t.Errorf("%s: got mate %d, want %d", test.name, info.Mate, test.mate)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package mcts is an engine which searches with Monte Carlo tree
+// search, choosing which moves to explore with UCT and evaluating
+// positions by playing random games to the end from them
+package mcts
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// Names of the player's options
+const (
+	// ExplorationOption is the exploration constant of UCT in
+	// hundredths. Larger values try less promising moves more often
+	ExplorationOption = "Exploration"
+	// PlayoutsOption is the most random games played for a search
+	// Zero means that the search goes on until it's stopped
+	PlayoutsOption = "Playouts"
+	// ThreadsOption is the number of threads searching the tree
+	ThreadsOption = "Threads"
+)
+
+const (
+	// DefaultExploration is close to the square root of two,
+	// which is the constant UCT is usually used with
+	DefaultExploration = 141
+	// MaxThreads is the most threads the player can search with
+	MaxThreads = 64
+	// maxPlayouts is the most that the playouts option can be set to
+	maxPlayouts = 1000000000
+	// reportInterval is how often the search is reported on
+	reportInterval = time.Second
+	// maxWinRate keeps scores finite when a move always wins or loses
+	maxWinRate = 0.999
+)
+
+// Player builds a tree of the moves it has tried, playing a random
+// game to the end from each new position. The move it has tried
+// the most is played as it's the one it has found to be the best
+type Player struct {
+	// Exploration is the exploration constant in hundredths
+	Exploration int
+	// Playouts is the most random games played, or zero for no limit
+	Playouts int
+	// Threads is the number of threads searching the tree
+	Threads int
+}
+
+// New creates an MCTS player with a single thread
+// which searches until it's told to stop
+func New() *Player {
+	return &Player{Exploration: DefaultExploration, Threads: 1}
+}
+
+// Name identifies the player
+func (p *Player) Name() string {
+	return "MCTS"
+}
+
+// Author identifies the player
+func (p *Player) Author() string {
+	return "Konnect4"
+}
+
+// Options are the options the player has
+func (p *Player) Options() []player.Option {
+	return []player.Option{
+		{Name: ExplorationOption, Type: player.Spin, Default: strconv.Itoa(DefaultExploration), Min: 0, Max: 1000},
+		{Name: PlayoutsOption, Type: player.Spin, Default: "0", Min: 0, Max: maxPlayouts},
+		{Name: ThreadsOption, Type: player.Spin, Default: "1", Min: 1, Max: MaxThreads},
+	}
+}
+
+// SetOption changes one of the player's options
+func (p *Player) SetOption(name, value string) error {
+	v, _ := strconv.Atoi(value)
+	switch name {
+	case ExplorationOption:
+		p.Exploration = v
+	case PlayoutsOption:
+		p.Playouts = v
+	case ThreadsOption:
+		p.Threads = v
+	}
+	return nil
+}
+
+// Search plays random games on each of the threads until it's
+// stopped or has played enough of them. The number of times each
+// column has been tried and how often it won are reported each
+// reportInterval along with the best move so far. The nodes limit
+// is the most random games to play and the depth limit isn't used
+func (p *Player) Search(s connect4.State, limits player.Limits) (int, player.Info) {
+	t := tree{
+		root:        &node{untried: limits.Moves(s)},
+		exploration: float64(p.Exploration) / 100,
+		playouts:    p.Playouts,
+	}
+	if limits.Nodes > 0 && (t.playouts == 0 || limits.Nodes < t.playouts) {
+		t.playouts = limits.Nodes
+	}
+	threads := p.Threads
+	if threads < 1 {
+		threads = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for !limits.Stopped() && t.playout(s, r) {
+			}
+		}(time.Now().UnixNano() + int64(i))
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+LOOP:
+	for {
+		select {
+		case <-done:
+			break LOOP
+		case <-ticker.C:
+			t.report(s, limits)
+		}
+	}
+	t.report(s, limits)
+	return t.best(s)
+}
+
+// node is a position in the tree, reached by playing move
+type node struct {
+	move   int
+	parent *node
+	// player is who played move, so the results are theirs
+	player   int
+	children []*node
+	// untried are the legal moves which haven't got children yet
+	untried []int
+	// visits is the number of random games played through the
+	// node and wins is the number of those that player won,
+	// with draws counting as half a win. visits are counted
+	// as soon as the game starts, so that other threads are
+	// less likely to explore the same position at the same time
+	visits int
+	wins   float64
+}
+
+// winRate is how often the move won
+func (n *node) winRate() float64 {
+	if n.visits == 0 {
+		return 0
+	}
+	return n.wins / float64(n.visits)
+}
+
+// tree is the tree of a search, shared by all of its threads
+type tree struct {
+	lock        sync.Mutex
+	root        *node
+	exploration float64
+	// playouts is the most random games to play, or zero for no limit
+	// and played is the number of random games which have been started
+	playouts, played int
+}
+
+// playout plays a random game from a new position in the tree and
+// records its result. false is returned if enough have been played
+func (t *tree) playout(s connect4.State, r *rand.Rand) bool {
+	t.lock.Lock()
+	if t.playouts > 0 && t.played >= t.playouts {
+		t.lock.Unlock()
+		return false
+	}
+	t.played++
+	n, s := t.expand(t.selection(s, r))
+	t.lock.Unlock()
+	winner := simulate(s, r)
+	t.lock.Lock()
+	for ; n != nil; n = n.parent {
+		switch winner {
+		case n.player:
+			n.wins++
+		case connect4.Tie:
+			n.wins += 0.5
+		}
+	}
+	t.lock.Unlock()
+	return true
+}
+
+// selection goes down the tree, choosing the child with the best
+// upper confidence bound each time, until it reaches a node which
+// has moves that haven't been tried or the game has finished
+func (t *tree) selection(s connect4.State, r *rand.Rand) (*node, connect4.State, *rand.Rand) {
+	n := t.root
+	n.visits++
+	for len(n.untried) == 0 && len(n.children) != 0 {
+		var best *node
+		bestValue := math.Inf(-1)
+		logVisits := math.Log(float64(n.visits))
+		for _, child := range n.children {
+			value := child.winRate() + t.exploration*math.Sqrt(logVisits/float64(child.visits))
+			if value > bestValue {
+				best, bestValue = child, value
+			}
+		}
+		n = best
+		s, _ = s.NextState(n.move)
+		n.visits++
+	}
+	return n, s, r
+}
+
+// expand adds a child for one of the moves of a node which
+// hasn't been tried, chosen at random. The node is returned
+// as it is if the game has finished in its position
+func (t *tree) expand(n *node, s connect4.State, r *rand.Rand) (*node, connect4.State) {
+	if len(n.untried) == 0 || s.Winner != connect4.Empty {
+		return n, s
+	}
+	i := r.Intn(len(n.untried))
+	move := n.untried[i]
+	n.untried[i] = n.untried[len(n.untried)-1]
+	n.untried = n.untried[:len(n.untried)-1]
+	next, err := s.NextState(move)
+	if err != nil {
+		return n, s
+	}
+	child := &node{move: move, parent: n, player: s.Player, visits: 1}
+	if next.Winner == connect4.Empty {
+		child.untried = legalMoves(next)
+	}
+	n.children = append(n.children, child)
+	return child, next
+}
+
+// simulate plays random moves until the game
+// is over, returning the winner or Tie
+func simulate(s connect4.State, r *rand.Rand) int {
+	for s.Winner == connect4.Empty {
+		moves := legalMoves(s)
+		s, _ = s.NextState(moves[r.Intn(len(moves))])
+	}
+	return s.Winner
+}
+
+// legalMoves gets the columns which can be played in a position
+func legalMoves(s connect4.State) []int {
+	result := make([]int, 0, 7)
+	for move, legal := range s.LegalActions() {
+		if legal {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// columns gets a line for each of the moves of the position which
+// have been tried, the most tried first. Each is scored by how often
+// its move won, with the number of times it was tried as the nodes
+func (t *tree) columns() []player.Info {
+	children := append([]*node{}, t.root.children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].visits > children[j].visits
+	})
+	result := make([]player.Info, 0, len(children))
+	for i, child := range children {
+		pv := variation(child)
+		result = append(result, player.Info{
+			MultiPV:  i + 1,
+			Depth:    len(pv),
+			HasScore: true,
+			Score:    score(child.winRate()),
+			Nodes:    child.visits,
+			PV:       pv,
+			Message:  fmt.Sprintf("winrate %.3f", child.winRate()),
+		})
+	}
+	return result
+}
+
+// report sends a line for each of the moves of the position
+// which have been tried, followed by the best move
+func (t *tree) report(s connect4.State, limits player.Limits) {
+	if limits.Report == nil {
+		return
+	}
+	t.lock.Lock()
+	columns := t.columns()
+	t.lock.Unlock()
+	for _, c := range columns {
+		limits.Report(c)
+	}
+	if _, info := t.best(s); info.HasScore {
+		limits.Report(info)
+	}
+}
+
+// variation gets the move of a node followed
+// by the moves tried the most after it
+func variation(n *node) []int {
+	result := []int{n.move}
+	for len(n.children) != 0 {
+		next := n.children[0]
+		for _, child := range n.children[1:] {
+			if child.visits > next.visits {
+				next = child
+			}
+		}
+		result = append(result, next.move)
+		n = next
+	}
+	return result
+}
+
+// score scales a win rate so that a win rate of 76% is a
+// score of about 230 and a win rate of 88% is about 400
+func score(rate float64) int {
+	rate = math.Max(1-maxWinRate, math.Min(maxWinRate, rate))
+	return int(math.Round(400 * math.Atanh(2*rate-1)))
+}
+
+// best gets the move which has been tried the most along
+// with the moves tried the most after it, scored by how
+// often the move won
+func (t *tree) best(s connect4.State) (int, player.Info) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	info := player.Info{Nodes: t.played}
+	if len(t.root.children) == 0 {
+		// Nothing has been tried yet
+		moves := t.root.untried
+		if len(moves) == 0 {
+			moves = legalMoves(s)
+		}
+		return moves[0], info
+	}
+	first := t.root.children[0]
+	for _, child := range t.root.children[1:] {
+		if child.visits > first.visits {
+			first = child
+		}
+	}
+	info.PV = variation(first)
+	info.Depth = len(info.PV)
+	info.HasScore = true
+	info.Score = score(first.winRate())
+	return first.move, info
+}
@@ -0,0 +1,139 @@
+package mcts
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// play gets the position after the moves
+// have been played from the start
+func play(t *testing.T, moves ...int) connect4.State {
+	s := connect4.NewState()
+	for _, move := range moves {
+		var err error
+		if s, err = s.NextState(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// collector keeps everything a search reports
+type collector struct {
+	lock  sync.Mutex
+	infos []player.Info
+}
+
+// report is used as the search's Report function
+func (c *collector) report(info player.Info) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.infos = append(c.infos, info)
+}
+
+func TestSearchWin(t *testing.T) {
+	p := New()
+	p.Playouts = 5000
+	move, info := p.Search(play(t, 0, 1, 0, 1, 0, 5), player.Limits{})
+	if move != 0 {
+		t.Errorf("got move %d, want the win in column 0", move)
+	}
+	if len(info.PV) == 0 || info.PV[0] != move {
+		t.Errorf("got pv %v for move %d", info.PV, move)
+	}
+}
+
+func TestSearchPlayouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		playouts int
+		nodes    int
+		want     int
+	}{
+		{"playouts", 300, 0, 300},
+		{"nodes limit", 0, 200, 200},
+		{"nodes limit below playouts", 300, 100, 100},
+		{"nodes limit above playouts", 300, 500, 300},
+	}
+	for _, test := range tests {
+		p := New()
+		p.Playouts = test.playouts
+		_, info := p.Search(connect4.NewState(), player.Limits{Nodes: test.nodes})
+		if info.Nodes != test.want {
+			t.Errorf("%s: played %d games, want %d", test.name, info.Nodes, test.want)
+		}
+	}
+}
+
+func TestSearchReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		searchMoves []int
+		columns     int
+	}{
+		{"every column", nil, 7},
+		{"search moves", []int{2, 3, 4}, 3},
+	}
+	for _, test := range tests {
+		p := New()
+		p.Playouts = 1000
+		c := &collector{}
+		p.Search(connect4.NewState(), player.Limits{SearchMoves: test.searchMoves, Report: c.report})
+		// The search is over before the first interval,
+		// so only the final report is made
+		var lines []player.Info
+		for _, info := range c.infos {
+			if info.MultiPV != 0 {
+				lines = append(lines, info)
+			}
+		}
+		if len(lines) != test.columns {
+			t.Fatalf("%s: got %d multipv lines, want %d", test.name, len(lines), test.columns)
+		}
+		tried := map[int]bool{}
+		visits := 0
+		for i, line := range lines {
+			if line.MultiPV != i+1 {
+				t.Errorf("%s: line %d has multipv %d", test.name, i, line.MultiPV)
+			}
+			if i > 0 && line.Nodes > lines[i-1].Nodes {
+				t.Errorf("%s: line %d was tried %d times, more than the %d before it", test.name, i, line.Nodes, lines[i-1].Nodes)
+			}
+			if len(line.PV) == 0 || tried[line.PV[0]] {
+				t.Errorf("%s: line %d has pv %v", test.name, i, line.PV)
+				continue
+			}
+			tried[line.PV[0]] = true
+			visits += line.Nodes
+		}
+		if visits != p.Playouts {
+			t.Errorf("%s: columns were tried %d times, want %d", test.name, visits, p.Playouts)
+		}
+	}
+}
+
+func TestSearchThreads(t *testing.T) {
+	p := New()
+	p.Playouts = 2000
+	p.Threads = 4
+	c := &collector{}
+	move, info := p.Search(play(t, 3, 3, 4), player.Limits{Report: c.report})
+	if info.Nodes != p.Playouts {
+		t.Errorf("played %d games, want %d", info.Nodes, p.Playouts)
+	}
+	if move < 0 || move >= 7 {
+		t.Errorf("got move %d", move)
+	}
+}
+
+func TestScore(t *testing.T) {
+	if got := score(0.5); got != 0 {
+		t.Errorf("got score %d for an even position, want 0", got)
+	}
+	if score(1) != score(maxWinRate) || score(0) != -score(1) {
+		t.Errorf("got scores %d and %d for moves which always win and lose, want them kept finite", score(1), score(0))
+	}
+}
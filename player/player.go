@@ -0,0 +1,207 @@
+// Package player lets connect four engines be written in Go. A Player
+// only needs to search positions, with Serve speaking CFP on its behalf
+// so that it can be run by Konnect4 in-process or as its own executable
+package player
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kappeh/Konnect4/connect4"
+)
+
+// Player is an engine written in Go
+type Player interface {
+	// Name and Author identify the player
+	Name() string
+	Author() string
+	// Search finds the best move in a position which hasn't finished,
+	// searching until limits.Stop is closed or a limit is reached.
+	// The best move is returned along with what is known about it
+	Search(s connect4.State, limits Limits) (int, Info)
+}
+
+// Configurable is a player with options which can be changed
+type Configurable interface {
+	Player
+	// Options are the options the player has
+	Options() []Option
+	// SetOption changes an option. name is the name of one of the
+	// options and value has already been checked to suit its type
+	SetOption(name, value string) error
+}
+
+// NewGamer is a player which needs to know when the next
+// search is from a different game, e.g. to clear its caches
+type NewGamer interface {
+	Player
+	NewGame()
+}
+
+// Limits restrict a search. Zero values mean that there is no limit
+type Limits struct {
+	// MoveTime is how long the search is expected to take. It is
+	// zero if it isn't known, e.g. while pondering, as the search
+	// goes on until Stop is closed anyway
+	MoveTime time.Duration
+	// Depth is the most moves ahead to search
+	Depth int
+	// Nodes is the most positions to search
+	Nodes int
+	// SearchMoves are the only columns to consider playing
+	SearchMoves []int
+	// Stop is closed once the search should stop
+	Stop <-chan struct{}
+	// Report sends information about the search while it's running
+	// It can be called from any goroutine. It is nil if the search
+	// isn't being reported on, e.g. when it's called directly
+	Report func(Info)
+}
+
+// Stopped is whether the search has been told to stop
+func (l Limits) Stopped() bool {
+	select {
+	case <-l.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Moves gets the legal moves of a position which
+// can be searched, taking SearchMoves into account
+func (l Limits) Moves(s connect4.State) []int {
+	legal := s.LegalActions()
+	var result []int
+	if len(l.SearchMoves) != 0 {
+		for _, move := range l.SearchMoves {
+			if move >= 0 && move < 7 && legal[move] {
+				result = append(result, move)
+			}
+		}
+		if len(result) != 0 {
+			return result
+		}
+	}
+	for move, ok := range legal {
+		if ok {
+			result = append(result, move)
+		}
+	}
+	return result
+}
+
+// Info is what a player knows about its search
+// Scores are from the point of view of the player to move
+type Info struct {
+	// MultiPV is the rank of the line when reporting
+	// on several moves, or zero for the best move
+	MultiPV int
+	Depth   int
+	// HasScore is whether Score or Mate is set
+	HasScore bool
+	Score    int
+	// Mate, if it isn't zero, is the number of moves until a
+	// forced win, negative if the player to move is losing
+	Mate  int
+	Nodes int
+	// PV is the moves expected to be played
+	PV []int
+	// Message is any other text, which is sent after the rest
+	Message string
+}
+
+// Empty is whether there isn't anything to report
+func (i Info) Empty() bool {
+	return i.MultiPV == 0 && i.Depth == 0 && !i.HasScore &&
+		i.Nodes == 0 && len(i.PV) == 0 && i.Message == ""
+}
+
+// String writes the info as the arguments of a CFP info command
+func (i Info) String() string {
+	var parts []string
+	if i.MultiPV > 0 {
+		parts = append(parts, "multipv", strconv.Itoa(i.MultiPV))
+	}
+	if i.Depth > 0 {
+		parts = append(parts, "depth", strconv.Itoa(i.Depth))
+	}
+	if i.HasScore && i.Mate != 0 {
+		parts = append(parts, "score", "mate", strconv.Itoa(i.Mate))
+	} else if i.HasScore {
+		parts = append(parts, "score", strconv.Itoa(i.Score))
+	}
+	if i.Nodes > 0 {
+		parts = append(parts, "nodes", strconv.Itoa(i.Nodes))
+	}
+	// The message comes before the pv as the pv takes
+	// the rest of the command
+	if i.Message != "" {
+		parts = append(parts, i.Message)
+	}
+	if len(i.PV) != 0 {
+		parts = append(parts, "pv")
+		for _, move := range i.PV {
+			parts = append(parts, strconv.Itoa(move))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Types of options
+const (
+	Check  = "check"
+	Spin   = "spin"
+	Combo  = "combo"
+	Button = "button"
+	String = "string"
+)
+
+// Option is a setting of a player, declared with CFP's option command
+type Option struct {
+	Name string
+	// Type is Check, Spin, Combo, Button or String
+	Type    string
+	Default string
+	// Min and Max are the range of a Spin
+	Min, Max int
+	// Vars are the values of a Combo
+	Vars []string
+}
+
+// String writes the option as the arguments of a CFP option command
+func (o Option) String() string {
+	parts := []string{"name", o.Name, "type", o.Type}
+	if o.Type != Button {
+		parts = append(parts, "default", o.Default)
+	}
+	if o.Type == Spin {
+		parts = append(parts, "min", strconv.Itoa(o.Min), "max", strconv.Itoa(o.Max))
+	}
+	for _, v := range o.Vars {
+		parts = append(parts, "var", v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// check makes sure that a value suits the option's type
+// The value is returned as it should be given to the player
+func (o Option) check(value string) (string, bool) {
+	switch o.Type {
+	case Check:
+		value = strings.ToLower(value)
+		return value, value == "true" || value == "false"
+	case Spin:
+		v, err := strconv.Atoi(value)
+		return value, err == nil && v >= o.Min && v <= o.Max
+	case Combo:
+		for _, v := range o.Vars {
+			if strings.EqualFold(v, value) {
+				return v, true
+			}
+		}
+		return value, false
+	}
+	return value, true
+}
@@ -0,0 +1,37 @@
+// Package random is an engine which plays random moves. It is the
+// weakest opponent possible, so any engine should beat it
+package random
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/Kappeh/Konnect4/connect4"
+	"github.com/Kappeh/Konnect4/player"
+)
+
+// Player plays a random legal move in every position
+type Player struct {
+	rand *rand.Rand
+}
+
+// New creates a random player
+func New() *Player {
+	return &Player{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Name identifies the player
+func (p *Player) Name() string {
+	return "Random"
+}
+
+// Author identifies the player
+func (p *Player) Author() string {
+	return "Konnect4"
+}
+
+// Search picks one of the moves at random straight away
+func (p *Player) Search(s connect4.State, limits player.Limits) (int, player.Info) {
+	moves := limits.Moves(s)
+	return moves[p.rand.Intn(len(moves))], player.Info{}
+}
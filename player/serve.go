@@ -0,0 +1,309 @@
+package player
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kappeh/Konnect4/connect4"
+)
+
+// Serve speaks CFP for a player, reading commands from r and writing
+// the player's responses to w. It returns once it's told to quit or
+// r has no more commands, stopping any search which is running
+// If the player panics, the panic is returned as an error
+func Serve(p Player, r io.Reader, w io.Writer) (err error) {
+	s := server{player: p, w: w, position: connect4.NewState()}
+	defer func() {
+		if v := recover(); v != nil {
+			err = panicError(v)
+		}
+	}()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+		if s.debug && len(args) != 0 {
+			s.send("info DEBUG: received %s", strings.Join(args, " "))
+		}
+		// Unknown tokens before the command are skipped
+		for len(args) != 0 && !commands[args[0]] {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" {
+			break
+		}
+		s.handle(args[0], args[1:])
+		if err := s.failed(); err != nil {
+			s.cancel()
+			return err
+		}
+	}
+	// Nothing is sent after quitting as the GUI
+	// is no longer listening, so only a panic
+	// while the search stops is an error
+	s.cancel()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.panic != nil {
+		return s.panic
+	}
+	return scanner.Err()
+}
+
+// commands are the commands Serve responds to
+var commands = map[string]bool{
+	"cfp": true, "isready": true, "debug": true, "setoption": true,
+	"cfpnewgame": true, "position": true, "go": true, "ponderhit": true,
+	"stop": true, "quit": true,
+}
+
+// server is the state of Serve
+type server struct {
+	player   Player
+	position connect4.State
+	debug    bool
+	// search is the search which is running, if there is one
+	search *search
+	// lock is held while writing to w, as a search
+	// can report its progress at any time
+	lock sync.Mutex
+	w    io.Writer
+	// err is why writing to w failed, if it did
+	err error
+	// panic is the panic of a search, if it panicked
+	panic error
+}
+
+// search is a search running in its own goroutine
+type search struct {
+	stop chan struct{}
+	// done is closed once move has been set
+	done chan struct{}
+	move int
+	info Info
+}
+
+// send writes a line to the GUI
+func (s *server) send(format string, args ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil || s.panic != nil {
+		return
+	}
+	_, s.err = fmt.Fprintf(s.w, format+"\n", args...)
+}
+
+// failed gets why the GUI can't be responded to, if it can't
+func (s *server) failed() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.panic != nil {
+		return s.panic
+	}
+	return s.err
+}
+
+// handle responds to a command
+func (s *server) handle(command string, args []string) {
+	switch command {
+	case "cfp":
+		s.send("id name %s", s.player.Name())
+		s.send("id author %s", s.player.Author())
+		if c, ok := s.player.(Configurable); ok {
+			for _, o := range c.Options() {
+				s.send("option %s", o)
+			}
+		}
+		s.send("cfpok")
+	case "isready":
+		s.send("readyok")
+	case "debug":
+		s.debug = len(args) == 0 || args[0] != "off"
+	case "setoption":
+		if err := s.setOption(args); err != nil {
+			s.send("info %s", err)
+		}
+	case "cfpnewgame":
+		if n, ok := s.player.(NewGamer); ok && s.search == nil {
+			n.NewGame()
+		}
+	case "position":
+		if s.search != nil || len(args) == 0 {
+			return
+		}
+		if args[0] == "startpos" {
+			s.position = connect4.NewState()
+		} else if position, err := connect4.StateFromCFP(args[0]); err == nil {
+			s.position = position
+		} else {
+			s.send("info invalid position %s", args[0])
+		}
+	case "go":
+		s.goCommand(args)
+	case "ponderhit":
+		// The search carries on as it is, as it
+		// runs until it's stopped regardless
+	case "stop":
+		s.stop()
+	}
+}
+
+// setOption changes one of the player's options
+// from the arguments of a setoption command
+func (s *server) setOption(args []string) error {
+	c, ok := s.player.(Configurable)
+	if !ok || s.search != nil {
+		return nil
+	}
+	// The name and value can both include spaces
+	var name, value []string
+	current := &name
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			current = &name
+		case "value":
+			current = &value
+		default:
+			*current = append(*current, arg)
+		}
+	}
+	for _, o := range c.Options() {
+		if !strings.EqualFold(o.Name, strings.Join(name, " ")) {
+			continue
+		}
+		v, ok := o.check(strings.Join(value, " "))
+		if !ok {
+			return fmt.Errorf("invalid value %q for option %s", strings.Join(value, " "), o.Name)
+		}
+		return c.SetOption(o.Name, v)
+	}
+	return fmt.Errorf("unknown option %s", strings.Join(name, " "))
+}
+
+// goCommand starts a search from the arguments of a go command
+func (s *server) goCommand(args []string) {
+	if s.search != nil {
+		return
+	}
+	position := s.position
+	limits := Limits{Report: func(info Info) {
+		s.send("info %s", info)
+	}}
+	// number parses the argument after the keyword at i
+	number := func(i int) (float64, bool) {
+		if i+1 >= len(args) {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(args[i+1], 64)
+		return v, err == nil
+	}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "ponder":
+			// The search is of the position after the move
+			if v, ok := number(i); ok {
+				next, err := position.NextState(int(v))
+				if err != nil {
+					s.send("info invalid ponder move %s", args[i+1])
+					return
+				}
+				position = next
+				i++
+			}
+		case "movetime":
+			if v, ok := number(i); ok {
+				limits.MoveTime = time.Duration(v * float64(time.Second))
+				i++
+			}
+		case "depth":
+			if v, ok := number(i); ok {
+				limits.Depth = int(v)
+				i++
+			}
+		case "nodes":
+			if v, ok := number(i); ok {
+				limits.Nodes = int(v)
+				i++
+			}
+		case "searchmoves":
+			for _, arg := range args[i+1:] {
+				move, err := strconv.Atoi(arg)
+				if err != nil {
+					break
+				}
+				limits.SearchMoves = append(limits.SearchMoves, move)
+			}
+			i += len(limits.SearchMoves)
+		}
+	}
+	search := &search{stop: make(chan struct{}), done: make(chan struct{})}
+	limits.Stop = search.stop
+	s.search = search
+	if position.Winner != connect4.Empty {
+		// There is nothing to search, e.g. when pondering on a move
+		// which ends the game, but a stop still needs a best move
+		close(search.done)
+		return
+	}
+	go func() {
+		defer close(search.done)
+		defer func() {
+			// The panic is reported by Serve as soon as it can
+			if v := recover(); v != nil {
+				s.lock.Lock()
+				s.panic = panicError(v)
+				s.lock.Unlock()
+			}
+		}()
+		search.move, search.info = s.player.Search(position, limits)
+		// What the player knows about its move is reported so that
+		// it's the latest info, even if the search reached a limit
+		// and is waiting for the stop
+		if !search.info.Empty() {
+			s.send("info %s", search.info)
+		}
+	}()
+}
+
+// panicError describes a panic along with where it happened
+func panicError(v interface{}) error {
+	return fmt.Errorf("player panicked: %v\n%s", v, debug.Stack())
+}
+
+// cancel stops the search which is running, if there is one
+// and waits for it to stop. The search is returned or nil
+func (s *server) cancel() *search {
+	search := s.search
+	if search == nil {
+		return nil
+	}
+	close(search.stop)
+	<-search.done
+	s.search = nil
+	return search
+}
+
+// stop stops the search which is running, if there is one,
+// and sends its best move once it has stopped
+func (s *server) stop() {
+	search := s.cancel()
+	if search == nil {
+		return
+	}
+	move, info := search.move, search.info
+	// The second move of the pv is the reply the player expects
+	if len(info.PV) >= 2 && info.PV[0] == move {
+		s.send("bestmove %d ponder %d", move, info.PV[1])
+	} else {
+		s.send("bestmove %d", move)
+	}
+}
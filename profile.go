@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ProfileExtension is the file extension of engine profiles
+	ProfileExtension = ".json"
+)
+
+// EngineProfile is a named engine configuration which is saved on
+// disk. It holds everything needed to load the engine and put
+// its options back to how they were when it was saved
+type EngineProfile struct {
+	// Name identifies the profile and is used as its file name
+	Name string `json:"name"`
+	// Engine is the definition used to run the engine
+	Engine EngineDefinition `json:"engine"`
+	// Options are the values of the engine's options
+	// Buttons are not included as they have no value
+	Options map[string]string `json:"options,omitempty"`
+	// Restart is the engine's restart policy
+	Restart RestartPolicy `json:"restart"`
+	// Ponder is whether the engine ponders in games
+	Ponder bool `json:"ponder"`
+}
+
+// NewProfile creates a profile from the current
+// configuration of a loaded engine
+func NewProfile(name string, e *Engine) EngineProfile {
+	result := EngineProfile{
+		Name:    name,
+		Engine:  e.Definition,
+		Options: make(map[string]string),
+		Restart: e.RestartPolicy(),
+		Ponder:  e.Ponders(),
+	}
+	for k, v := range e.Options() {
+		if value, ok := OptionValue(v); ok {
+			result.Options[k] = value
+		}
+	}
+	return result
+}
+
+// LoadProfiles reads every profile within ProfileDirectory
+// The profiles are ordered by name. If the directory
+// doesn't exist, there are no profiles. A profile which
+// can't be read is skipped, with why returned along
+// with the profiles which could be read
+func LoadProfiles() ([]EngineProfile, []error) {
+	files, err := ioutil.ReadDir(ProfileDirectory)
+	if os.IsNotExist(err) {
+		return []EngineProfile{}, nil
+	} else if err != nil {
+		return []EngineProfile{}, []error{errors.Wrap(err, "couldn't read profile directory")}
+	}
+	result := []EngineProfile{}
+	var failures []error
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ProfileExtension) {
+			continue
+		}
+		profile, err := loadProfile(filepath.Join(ProfileDirectory, file.Name()))
+		if err != nil {
+			failures = append(failures, errors.Wrapf(err, "skipped profile %s", file.Name()))
+			continue
+		}
+		result = append(result, profile)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, failures
+}
+
+// loadProfile reads a single profile
+func loadProfile(path string) (EngineProfile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return EngineProfile{}, errors.Wrap(err, "couldn't read profile")
+	}
+	profile := EngineProfile{}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return EngineProfile{}, errors.Wrap(err, "couldn't parse profile")
+	}
+	if err := profile.Engine.Validate(); err != nil {
+		return EngineProfile{}, errors.Wrap(err, "invalid profile")
+	}
+	return profile, nil
+}
+
+// Save writes the profile to ProfileDirectory
+// Any existing profile with the same name is overwritten
+func (p EngineProfile) Save() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("profile name can't be empty")
+	}
+	if err := os.MkdirAll(ProfileDirectory, 0755); err != nil {
+		return errors.Wrap(err, "couldn't make profile directory")
+	}
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode profile")
+	}
+	path := filepath.Join(ProfileDirectory, profileFileName(p.Name))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.Wrap(err, "couldn't write profile")
+	}
+	return nil
+}
+
+// profileFileName gets the name of the file a profile is saved in
+// Anything other than letters, digits, dashes and underscores
+// is replaced so the name is safe to use as a file name
+func profileFileName(name string) string {
+	result := []rune(strings.TrimSpace(name))
+	for i, r := range result {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			result[i] = '_'
+		}
+	}
+	return string(result) + ProfileExtension
+}
+
+// OptionValue gets the value of an option as a string
+// Buttons have no value, so false is returned for them
+func OptionValue(o Option) (string, bool) {
+	switch v := o.(type) {
+	case CheckBox:
+		return strconv.FormatBool(v.Value), true
+	case Spinner:
+		return strconv.Itoa(v.Value), true
+	case ComboBox:
+		return v.Value, true
+	case String:
+		return v.Value, true
+	default:
+		return "", false
+	}
+}
@@ -26,14 +26,30 @@ type Protocol interface {
 	// preceeded by a call to NewGame()
 	Position(State) error
 	// Go tells the engine that it should start analysing the
-	// position and the maximum amount of time it has to think
-	Go(time.Duration) error
+	// position, the maximum amount of time it has to think
+	// and any limits to its search
+	Go(time.Duration, SearchLimits) error
+	// GoPonder tells the engine to start analysing the position
+	// as if the move had been played in it, while its opponent
+	// thinks about which move to play
+	GoPonder(int) error
+	// PonderHit tells a pondering engine that the move it was
+	// pondering on was played. It carries on analysing with
+	// the time it has been given to make its move
+	PonderHit(time.Duration) error
 	// Stop tells the engine to stop thinking as soon as possible
 	// The best move the engine found is returned
 	Stop() (int, error)
+	// PonderMove gets the move the engine suggested pondering on
+	// along with its last best move. false is returned if it didn't
+	PonderMove() (int, bool)
 	// Quit should close all connections to the process. and
 	// tell the engine to quit as soon as possible.
 	Quit() error
+	// LastInfo gets the latest structured information the engine
+	// reported about its current or most recent search. false is
+	// returned if it hasn't reported any since it was told to go
+	LastInfo() (SearchInfo, bool)
 	// NotifyInfo tells the protocol to send any info events to
 	// the provided channel
 	NotifyInfo(chan<- string)
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxRestarts is the maximum number of crashes an
+// engine is restarted after when its policy doesn't say
+const DefaultMaxRestarts = 3
+
+// RestartPolicy decides whether an engine should be restarted
+// after its process crashes. It is disabled by default
+type RestartPolicy struct {
+	// Enabled is whether crashed engines are restarted at all
+	Enabled bool `json:"enabled"`
+	// MaxRestarts is the maximum number of crashes the engine
+	// will be restarted after. 0 means DefaultMaxRestarts
+	MaxRestarts int `json:"maxrestarts,omitempty"`
+}
+
+// allows returns true if an engine that has crashed
+// a given number of times should be restarted
+func (r RestartPolicy) allows(crashes int) bool {
+	if !r.Enabled {
+		return false
+	}
+	max := r.MaxRestarts
+	if max <= 0 {
+		max = DefaultMaxRestarts
+	}
+	return crashes <= max
+}
+
+// SetRestart sets whether the engine is restarted
+// after its process crashes
+func (e *Engine) SetRestart(policy RestartPolicy) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.restartPolicy = policy
+}
+
+// RestartPolicy gets whether the engine is
+// restarted after its process crashes
+func (e *Engine) RestartPolicy() RestartPolicy {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.restartPolicy
+}
+
+// restart starts a new process for the engine after it crashed.
+// Once the handshake is performed, every option which was changed
+// from its default is set again and debug mode is restored so
+// that the engine carries on as it was before it crashed
+// No commands are sent to the engine while it's restarted. A process
+// which can't be put back to how the engine was is stopped
+func (e *Engine) restart() error {
+	e.commandLock.Lock()
+	defer e.commandLock.Unlock()
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return errors.New("engine has been told to quit")
+	}
+	e.restarting = true
+	e.lock.Unlock()
+	err := e.restore()
+	if err != nil && !e.HasExited() {
+		e.stop()
+		<-e.Exited()
+	}
+	e.lock.Lock()
+	e.restarting = false
+	e.lock.Unlock()
+	return err
+}
+
+// restore starts a new process for the engine and restores
+// its options and debug mode while commandLock is held
+func (e *Engine) restore() error {
+	// Starting a new process and handshaking with it
+	if err := e.newProcess(); err != nil {
+		return errors.Wrap(err, "couldn't create engine process")
+	}
+	_, _, options, err := e.start()
+	if err != nil {
+		return err
+	}
+	// Reapplying options which were changed from their defaults
+	// Options that the new process doesn't specify are dropped
+	e.lock.Lock()
+	previous, defaults := e.options, e.defaults
+	e.lock.Unlock()
+	e.setOptions(options)
+	for name, option := range previous {
+		if _, ok := option.(Button); ok {
+			continue
+		}
+		if _, ok := options[name]; !ok {
+			continue
+		}
+		if reflect.DeepEqual(option, defaults[name]) {
+			continue
+		}
+		if err := e.setOption(option); err != nil {
+			return errors.Wrapf(err, "couldn't reapply option %s", name)
+		}
+	}
+	// Restoring debug mode
+	if e.Debugging() {
+		if err := e.setDebug(true); err != nil {
+			return errors.Wrap(err, "couldn't restore debug mode")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// runMatch plays a match between two engines without the user
+// interface, printing the progress and the final score. The exit
+// code is non-zero if an engine failed during the match
+func runMatch(args []string) int {
+	flags := flag.NewFlagSet(ApplicationName+" match", flag.ContinueOnError)
+	engine1 := flags.String("engine1", "", "path of the first engine, RELATIVE to the engine directory")
+	engine2 := flags.String("engine2", "", "path of the second engine, RELATIVE to the engine directory")
+	games := flags.Int("games", 2, "number of games to play")
+	tc := flags.String("tc", "", "time control as base+increment in seconds, e.g. 60+0.5 (default is -movetime for each move)")
+	moveTime := flags.Duration("movetime", 0, "time given for each move without a time control (default is the configured turntime)")
+	openings := flags.String("openings", "", "file of positions to start games from, each played with both sides")
+	out := flags.String("out", "", "file to write the games to in C4N")
+	resign := flags.String("resign", "", "adjudicate a win once both engines score a player ahead by at least score for moves each, as moves:score")
+	draw := flags.String("draw", "", "adjudicate a draw once both engines score within score of zero for moves each, as moves:score")
+	solve := flags.Int("solve", 0, fmt.Sprintf("adjudicate games by solving them once at most this many cells are empty, up to %d", MaxSolveEmpty))
+	ponder := flags.Bool("ponder", false, "let the engines think while their opponent is thinking")
+	workers := flags.String("workers", "", "comma separated addresses of workers to play the games on instead of locally, engine paths are then RELATIVE to the workers' engine directories")
+	configuration := addConfigFlags(flags)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if *engine1 == "" || *engine2 == "" {
+		fmt.Fprintln(os.Stderr, "both -engine1 and -engine2 are needed")
+		return 2
+	}
+	config, err := configuration.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.UseDirectories()
+	if err := playMatch(config, matchOptions{
+		engine1:  *engine1,
+		engine2:  *engine2,
+		games:    *games,
+		tc:       *tc,
+		moveTime: *moveTime,
+		openings: *openings,
+		out:      *out,
+		resign:   *resign,
+		draw:     *draw,
+		solve:    *solve,
+		ponder:   *ponder,
+		workers:  *workers,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// matchOptions are the flags of the match command
+type matchOptions struct {
+	engine1, engine2 string
+	games            int
+	tc               string
+	moveTime         time.Duration
+	openings         string
+	out              string
+	// resign and draw are adjudication rules written as moves:score
+	resign, draw string
+	solve        int
+	ponder       bool
+	// workers are the comma separated addresses
+	// of the workers the games are played on
+	workers string
+}
+
+// matchPlayer plays a match, either with
+// local engines or on workers
+type matchPlayer interface {
+	NotifyResults(channel chan<- GameResult)
+	Start() (<-chan struct{}, error)
+	Stop()
+	Summary() MatchSummary
+}
+
+// playMatch sets up and plays a match with the given options
+// An error is returned if the match couldn't be completed
+// or either of the engines crashed
+func playMatch(config Config, options matchOptions) error {
+	// Setting up the match
+	if options.moveTime <= 0 {
+		options.moveTime = time.Duration(config.TurnTime)
+	}
+	var timeControl *TimeControl
+	if options.tc != "" {
+		tc, err := ParseTimeControl(options.tc)
+		if err != nil {
+			return errors.Wrap(err, "invalid -tc")
+		}
+		timeControl = &tc
+	}
+	adjudication, err := options.adjudication()
+	if err != nil {
+		return err
+	}
+	var openings []State
+	if options.openings != "" {
+		var err error
+		if openings, err = LoadOpenings(options.openings); err != nil {
+			return err
+		}
+	}
+	var out *os.File
+	if options.out != "" {
+		var err error
+		if out, err = os.Create(options.out); err != nil {
+			return errors.Wrap(err, "couldn't create output file")
+		}
+		defer out.Close()
+	}
+	// Loading the engines or connecting to the workers
+	var (
+		match            matchPlayer
+		engines          []*Engine
+		workerErrors     chan error
+		engine1, engine2 = options.engine1, options.engine2
+	)
+	if options.workers != "" {
+		coordinator, err := NewCoordinator(
+			options.engine1, options.engine2, strings.Split(options.workers, ","),
+			options.games, options.moveTime,
+		)
+		if err != nil {
+			return err
+		}
+		coordinator.TimeControl = timeControl
+		coordinator.Openings = openings
+		coordinator.Adjudication = adjudication
+		coordinator.Ponder = options.ponder
+		workerErrors = make(chan error)
+		coordinator.NotifyWorkerErrors(workerErrors)
+		match = coordinator
+	} else {
+		e1, err := loadMatchEngine(options.engine1, config.CFPTimeouts())
+		if err != nil {
+			return errors.Wrap(err, "couldn't load engine1")
+		}
+		defer e1.Quit()
+		e2, err := loadMatchEngine(options.engine2, config.CFPTimeouts())
+		if err != nil {
+			return errors.Wrap(err, "couldn't load engine2")
+		}
+		defer e2.Quit()
+		e1.SetPonder(options.ponder)
+		e2.SetPonder(options.ponder)
+		local, err := NewMatch(e1, e2, options.games, options.moveTime)
+		if err != nil {
+			return err
+		}
+		local.TimeControl = timeControl
+		local.Openings = openings
+		local.Adjudication = adjudication
+		match = local
+		engines = []*Engine{e1, e2}
+		engine1, engine2 = e1.Name, e2.Name
+	}
+	// Stopping the match after the current game on an interrupt
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	go func() {
+		if _, ok := <-interrupts; ok {
+			fmt.Println("Interrupted, stopping after the current game")
+			match.Stop()
+		}
+	}()
+	// Playing the match
+	fmt.Printf("Match of %d games between %s and %s\n", options.games, engine1, engine2)
+	results := make(chan GameResult)
+	match.NotifyResults(results)
+	done, err := match.Start()
+	if err != nil {
+		return err
+	}
+	tags := []C4NTag{
+		{"Event", ApplicationName + " match"},
+		{"Date", time.Now().Format("2006.01.02")},
+	}
+	if timeControl != nil {
+		tags = append(tags, C4NTag{"TimeControl", timeControl.String()})
+	} else {
+		tags = append(tags, C4NTag{"MoveTime", options.moveTime.String()})
+	}
+	var played []GameResult
+LOOP:
+	for {
+		select {
+		case result := <-results:
+			played = append(played, result)
+			round := len(played)
+			if round == 1 && engines == nil {
+				// The engines are named by the workers
+				engine1, engine2 = result.Player1, result.Player2
+			}
+			wins1, wins2, draws := MatchScore(played)
+			fmt.Printf(
+				"Game %d of %d: %s vs %s %s by %s, score %s %d - %d %s with %d draws\n",
+				round, options.games, result.Player1, result.Player2, c4nResult(result.Winner), result.Reason,
+				engine1, wins1, wins2, engine2, draws,
+			)
+			if out == nil {
+				continue
+			}
+			gameTags := append(tags, C4NTag{"Round", fmt.Sprint(round)})
+			if err := WriteC4N(out, gameTags, result); err != nil {
+				match.Stop()
+				return errors.Wrap(err, "couldn't write game")
+			}
+		case err := <-workerErrors:
+			fmt.Println(err)
+		case <-done:
+			break LOOP
+		}
+	}
+	// Reporting the final score
+	summary := match.Summary()
+	engine1, engine2 = summary.Engine1, summary.Engine2
+	elo, margin := EloDifference(summary.Wins1, summary.Wins2, summary.Draws)
+	fmt.Printf(
+		"Final score %s %d - %d %s with %d draws after %d games\n",
+		engine1, summary.Wins1, summary.Wins2, engine2, summary.Draws, summary.Played,
+	)
+	fmt.Printf("Elo difference %s\n", formatElo(elo, margin))
+	if len(summary.Reasons) != 0 {
+		reasons := make([]string, 0, len(summary.Reasons))
+		for reason, count := range summary.Reasons {
+			reasons = append(reasons, fmt.Sprintf("%s %d", reason, count))
+		}
+		sort.Strings(reasons)
+		fmt.Printf("Games finished by %s\n", strings.Join(reasons, ", "))
+	}
+	if summary.Error != "" {
+		return errors.New(summary.Error)
+	}
+	for _, e := range engines {
+		if crashes := e.Crashes(); crashes > 0 {
+			return errors.Errorf("engine %s crashed %d times, %s", e.Name, crashes, e.LastExit())
+		}
+	}
+	return nil
+}
+
+// adjudication creates the adjudication of the match from the
+// options. nil is returned if games aren't to be adjudicated
+func (options matchOptions) adjudication() (*Adjudication, error) {
+	if options.resign == "" && options.draw == "" && options.solve == 0 {
+		return nil, nil
+	}
+	result := Adjudication{SolveEmpty: options.solve}
+	var err error
+	if options.resign != "" {
+		if result.ResignMoves, result.ResignScore, err = ParseAdjudicationRule(options.resign); err != nil {
+			return nil, errors.Wrap(err, "invalid -resign")
+		}
+	}
+	if options.draw != "" {
+		if result.DrawMoves, result.DrawScore, err = ParseAdjudicationRule(options.draw); err != nil {
+			return nil, errors.Wrap(err, "invalid -draw")
+		}
+	}
+	if err := result.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid adjudication")
+	}
+	return &result, nil
+}
+
+// loadMatchEngine loads an engine from a path
+// RELATIVE to EngineDirectory
+func loadMatchEngine(path string, timeouts CFPTimeouts) (*Engine, error) {
+	definition, err := LoadDefinition(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't load engine definition")
+	}
+	engine, err := NewEngine(definition, NewCFP(definition.Timeouts.CFP(timeouts)))
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create engine")
+	}
+	if err := engine.Load(); err != nil {
+		return nil, errors.Wrap(err, "couldn't start engine")
+	}
+	return engine, nil
+}
+
+// formatElo writes an Elo difference along with its margin
+func formatElo(elo, margin float64) string {
+	if math.IsInf(elo, 0) {
+		return fmt.Sprintf("%+.0f", elo)
+	}
+	if math.IsInf(margin, 0) || math.IsNaN(margin) {
+		return fmt.Sprintf("%+.1f", elo)
+	}
+	return fmt.Sprintf("%+.1f +/- %.1f", elo, margin)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SearchLimits restrict the search an engine does after being told
+// to go, so that the search can be repeated when debugging
+// Limits which are zero or empty don't restrict the search
+type SearchLimits struct {
+	// Depth is how many moves ahead the engine searches
+	Depth int `json:"depth,omitempty"`
+	// Nodes is how many positions the engine searches
+	Nodes int `json:"nodes,omitempty"`
+	// SearchMoves are the only columns the engine
+	// considers playing in the position
+	SearchMoves []int `json:"searchmoves,omitempty"`
+}
+
+// Validate checks that the limits can be sent to an engine
+func (l SearchLimits) Validate() error {
+	if l.Depth < 0 {
+		return errors.New("depth can't be negative")
+	}
+	if l.Nodes < 0 {
+		return errors.New("number of nodes can't be negative")
+	}
+	var seen [7]bool
+	for _, move := range l.SearchMoves {
+		if move < 0 || move > 6 {
+			return errors.Errorf("search move %d isn't a column", move)
+		}
+		if seen[move] {
+			return errors.Errorf("search move %d is repeated", move)
+		}
+		seen[move] = true
+	}
+	return nil
+}
+
+// Limited is whether any of the limits restrict the search
+func (l SearchLimits) Limited() bool {
+	return l.Depth > 0 || l.Nodes > 0 || len(l.SearchMoves) != 0
+}
+
+// Position gets the limits to use when searching a position
+// Search moves which aren't legal in the position are left out,
+// and if none of them are legal, the moves aren't restricted
+func (l SearchLimits) Position(s State) SearchLimits {
+	result := SearchLimits{Depth: l.Depth, Nodes: l.Nodes}
+	legal := s.LegalActions()
+	for _, move := range l.SearchMoves {
+		if move >= 0 && move <= 6 && legal[move] {
+			result.SearchMoves = append(result.SearchMoves, move)
+		}
+	}
+	return result
+}
+
+// String writes the limits as the arguments of a go command
+// e.g. depth 8 nodes 100000 searchmoves 2 3 4
+// searchmoves comes last as it takes the rest of the arguments
+func (l SearchLimits) String() string {
+	var args []string
+	if l.Depth > 0 {
+		args = append(args, fmt.Sprintf("depth %d", l.Depth))
+	}
+	if l.Nodes > 0 {
+		args = append(args, fmt.Sprintf("nodes %d", l.Nodes))
+	}
+	if len(l.SearchMoves) != 0 {
+		args = append(args, "searchmoves")
+		for _, move := range l.SearchMoves {
+			args = append(args, fmt.Sprint(move))
+		}
+	}
+	return strings.Join(args, " ")
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -12,9 +13,6 @@ import (
 )
 
 const (
-	// MaxConnections is the Maximum number of sockets that
-	// should exist at any given time
-	MaxConnections = 100
 	// EventBufferSize is the buffer size of the channels
 	// holding events
 	EventBufferSize = 10
@@ -24,10 +22,14 @@ const (
 // maintainance and communications of websockets.
 type Server struct {
 	lock           sync.RWMutex
-	clients        map[int]*websocket.Conn
+	clients        map[int]*client
 	nextClientID   int
 	connections    int
 	maxConnections int
+	// address is the address the server listens on
+	address string
+	// legacy enables the legacy text protocol endpoint
+	legacy bool
 
 	// staticAddress is the path to the root of the static
 	// content to be served
@@ -39,30 +41,51 @@ type Server struct {
 	upgrader websocket.Upgrader
 }
 
-// ServerEvent is triggered when a command should be sent to
+// client is a WebSocket connection to the server
+type client struct {
+	// writeLock stops messages being written at the same time
+	writeLock sync.Mutex
+	conn      *websocket.Conn
+	// legacy is true if the client uses the legacy text protocol
+	legacy bool
+}
+
+// ServerEvent is triggered when a message should be sent to
 // all connected sockets
 type ServerEvent struct {
-	WSCommand string
+	Message Message
 }
 
 // ClientEvent is when a client has messaged the server
 // via a WebSocket. Usually requesting something to happen
 // on the server
 type ClientEvent struct {
-	ClientID  int
-	WsCommand string
+	ClientID int
+	// RequestID is the id the client gave the request
+	// Any response to the request is given the same id
+	RequestID int
+	// Request is one of the request types in messages.go
+	Request interface{}
+	// responses is where responses are sent for requests
+	// which didn't come from a WebSocket
+	responses chan<- Message
 }
 
-// NewServer creates a new server
-func NewServer(staticAddress string) (*Server, error) {
+// NewServer creates a new server which listens on address
+// and allows up to maxConnections WebSockets at once.
+// If legacy is true, clients can also connect using the
+// legacy text protocol
+func NewServer(staticAddress, address string, maxConnections int, legacy bool) (*Server, error) {
 	if _, err := os.Stat(staticAddress); os.IsNotExist(err) {
 		return nil, errors.Wrap(err, "couldn't find engines root directory")
 	} else if err != nil {
 		return nil, errors.Wrap(err, "couldn't find engines root directory")
 	}
 	return &Server{
-		clients:        make(map[int]*websocket.Conn),
-		maxConnections: MaxConnections,
+		clients:        make(map[int]*client),
+		maxConnections: maxConnections,
+		address:        address,
+		legacy:         legacy,
 		staticAddress:  staticAddress,
 		serverEvents:   make(chan ServerEvent, EventBufferSize),
 		upgrader: websocket.Upgrader{
@@ -81,10 +104,14 @@ func (s *Server) Start() error {
 	// Setting up routes
 	http.HandleFunc("/", s.staticHandler)
 	http.HandleFunc("/ws", s.wsEndpoint)
+	http.HandleFunc("/api/", s.apiHandler)
+	if s.legacy {
+		http.HandleFunc("/ws/legacy", s.legacyEndpoint)
+	}
 	// Listening to server events
 	go s.serverEventListener()
 	// Serving content to clients
-	return http.ListenAndServe(":8080", nil)
+	return http.ListenAndServe(s.address, nil)
 }
 
 // TriggerEvent is used to send a command to all WebSocket connections
@@ -94,14 +121,16 @@ func (s *Server) TriggerEvent(evt ServerEvent) {
 
 // Respond is used to send a message back to a client
 // after a request from ClientEvent.
-func (s *Server) Respond(evt ClientEvent, response string) {
+func (s *Server) Respond(evt ClientEvent, response Message) {
+	if evt.responses != nil {
+		evt.responses <- response
+		return
+	}
 	s.lock.RLock()
 	client, ok := s.clients[evt.ClientID]
-	if !ok {
-		s.lock.RUnlock()
-		return
+	if ok {
+		client.send(evt.RequestID, response)
 	}
-	client.WriteMessage(websocket.TextMessage, []byte(response))
 	s.lock.RUnlock()
 }
 
@@ -132,6 +161,8 @@ func (s *Server) staticHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/css")
 	} else if strings.HasSuffix(path, ".js") {
 		w.Header().Add("Content-Type", "text/javascript")
+	} else if strings.HasSuffix(path, ".json") {
+		w.Header().Add("Content-Type", "application/json")
 	}
 	w.Header().Add("Content-Type", contentType)
 	// Responding with content
@@ -139,8 +170,19 @@ func (s *Server) staticHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // wsEndpoint handles http requests trying to establish
-// a WebSocket connection
+// a WebSocket connection which uses the JSON protocol
 func (s *Server) wsEndpoint(w http.ResponseWriter, r *http.Request) {
+	s.connect(w, r, false)
+}
+
+// legacyEndpoint handles http requests trying to establish
+// a WebSocket connection which uses the legacy text protocol
+func (s *Server) legacyEndpoint(w http.ResponseWriter, r *http.Request) {
+	s.connect(w, r, true)
+}
+
+// connect establishes a WebSocket connection
+func (s *Server) connect(w http.ResponseWriter, r *http.Request, legacy bool) {
 	// Checking if there are too many connections
 	s.lock.RLock()
 	allowConnection := s.connections < s.maxConnections
@@ -156,29 +198,48 @@ func (s *Server) wsEndpoint(w http.ResponseWriter, r *http.Request) {
 	// Adding reference of WebSocket to Server
 	s.lock.Lock()
 	clientID := s.nextClientID
-	s.clients[clientID] = ws
+	c := &client{conn: ws, legacy: legacy}
+	s.clients[clientID] = c
 	s.connections++
 	s.nextClientID++
 	s.lock.Unlock()
 
 	// Listening to the socket
-	go s.socketListener(clientID, ws)
+	go s.socketListener(clientID, c)
 }
 
 // socketListener listens to WebSocket connections for
 // requests from clients
-func (s *Server) socketListener(clientID int, conn *websocket.Conn) {
+func (s *Server) socketListener(clientID int, c *client) {
 	for {
-		_, p, err := conn.ReadMessage()
+		_, p, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
 		if s.clientEvents == nil {
 			continue
 		}
+		// Decode the request using the client's protocol
+		var (
+			id      int
+			request interface{}
+		)
+		if c.legacy {
+			request, err = parseLegacyCommand(string(p))
+		} else {
+			id, request, err = decodeRequest(p)
+		}
+		if err != nil {
+			c.send(id, ErrorMessage{Message: err.Error()})
+			continue
+		}
+		if request == nil {
+			continue
+		}
 		s.clientEvents <- ClientEvent{
 			ClientID:  clientID,
-			WsCommand: string(p),
+			RequestID: id,
+			Request:   request,
 		}
 	}
 	s.removeClient(clientID)
@@ -196,7 +257,7 @@ func (s *Server) removeClient(clientID int) {
 	}
 	// Removing client
 	s.lock.Lock()
-	client.Close()
+	client.conn.Close()
 	delete(s.clients, clientID)
 	s.connections--
 	s.lock.Unlock()
@@ -210,16 +271,36 @@ func (s *Server) serverEventListener() {
 		if !ok {
 			return
 		}
-		s.commandToAll(evt.WSCommand)
+		s.messageToAll(evt.Message)
 	}
 }
 
-// commandToAll sends a command string to each connected
+// messageToAll sends a message to each connected
 // client through their respective WebSocket
-func (s *Server) commandToAll(command string) {
+func (s *Server) messageToAll(m Message) {
 	s.lock.RLock()
 	for _, v := range s.clients {
-		v.WriteMessage(websocket.TextMessage, []byte(command))
+		v.send(0, m)
 	}
 	s.lock.RUnlock()
 }
+
+// send writes a message to the client using its protocol
+// id is the id of the request being responded to, if any
+func (c *client) send(id int, m Message) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.legacy {
+		for _, command := range m.LegacyCommands() {
+			c.conn.WriteMessage(websocket.TextMessage, []byte(command))
+		}
+		return
+	}
+	data, err := encodeMessage(id, m)
+	if err != nil {
+		data, _ = encodeMessage(id, ErrorMessage{
+			Message: fmt.Sprintf("couldn't encode %s message: %s", m.MessageType(), err),
+		})
+	}
+	c.conn.WriteMessage(websocket.TextMessage, data)
+}
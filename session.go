@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// OutputHistorySize is the number of output messages that
+	// are kept in the session
+	OutputHistorySize = 200
+)
+
+// Session is a snapshot of everything the user has set up in
+// Develop. It's written to SessionFile whenever something
+// changes so that it can be restored after a restart
+type Session struct {
+	// Time is when the snapshot was taken
+	Time time.Time `json:"time"`
+	// Engines are the engines which were loaded
+	Engines []SessionEngine `json:"engines"`
+	// NextEngineID is the id allocated for the next engine
+	NextEngineID int `json:"nextengineid"`
+	// Player1 and Player2 are the ids of the engines selected
+	// to play the game, -1 if a player isn't set
+	Player1 int `json:"player1"`
+	Player2 int `json:"player2"`
+	// History is the positions that have been visited over the
+	// course of the game in the CFP representation
+	History []string `json:"history"`
+	// Searches are how the players found each move
+	Searches []SearchMessage `json:"searches,omitempty"`
+	// Winner is the winner of the game. It's kept separately
+	// from the positions as a game can be adjudicated
+	Winner int `json:"winner"`
+	// Reason is why the game finished, if it did
+	Reason string `json:"reason,omitempty"`
+	// TurnTime is the time the players are given for each move
+	TurnTime Duration `json:"turntime"`
+	// Limits restrict the players' searches
+	Limits SearchLimits `json:"limits"`
+	// Output is the most recent messages sent to the output terminal
+	Output []OutputMessage `json:"output,omitempty"`
+}
+
+// SessionEngine is a loaded engine within a session
+type SessionEngine struct {
+	// ID is the id the engine was loaded with
+	ID int `json:"id"`
+	// Profile describes how to load and configure the engine
+	Profile EngineProfile `json:"profile"`
+	// Debug is whether the engine's debug mode was enabled
+	Debug bool `json:"debug,omitempty"`
+}
+
+// OutputMessage is a message which has been sent to
+// the output terminal
+type OutputMessage struct {
+	Time    time.Time `json:"time"`
+	Sender  string    `json:"sender"`
+	Message string    `json:"message"`
+}
+
+// sessionLock stops several snapshots being written at once
+var sessionLock sync.Mutex
+
+// LoadSession reads the session in SessionFile
+// If there isn't a saved session, false is returned
+func LoadSession() (Session, bool, error) {
+	data, err := ioutil.ReadFile(SessionFile)
+	if os.IsNotExist(err) {
+		return Session{}, false, nil
+	} else if err != nil {
+		return Session{}, false, errors.Wrap(err, "couldn't read session")
+	}
+	result := Session{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Session{}, false, errors.Wrap(err, "couldn't parse session")
+	}
+	for _, engine := range result.Engines {
+		if err := engine.Profile.Engine.Validate(); err != nil {
+			return Session{}, false, errors.Wrapf(err, "invalid engine %d", engine.ID)
+		}
+	}
+	return result, true, nil
+}
+
+// Save writes the session to SessionFile
+// The file is replaced in one step so a crash while saving
+// never leaves a partially written session behind
+func (s Session) Save() error {
+	sessionLock.Lock()
+	defer sessionLock.Unlock()
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode session")
+	}
+	tmp := SessionFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "couldn't write session")
+	}
+	if err := os.Rename(tmp, SessionFile); err != nil {
+		return errors.Wrap(err, "couldn't replace session")
+	}
+	return nil
+}
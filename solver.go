@@ -0,0 +1,59 @@
+package main
+
+// solverOrder is the order the solver tries moves in. Central
+// columns are tried first as they are usually the best moves
+var solverOrder = [7]int{3, 2, 4, 1, 5, 0, 6}
+
+// Solve finds the result of a position when both players play
+// perfectly: Player1, Player2 or Tie. Every line of play is searched
+// to the end of the game, so it's only suitable for positions
+// with few empty cells
+func Solve(s State) int {
+	if s.Winner != Empty {
+		return s.Winner
+	}
+	switch solve(s, -1, 1) {
+	case 1:
+		return s.Player
+	case -1:
+		if s.Player == Player1 {
+			return Player2
+		}
+		return Player1
+	}
+	return Tie
+}
+
+// solve is a negamax search of a position which hasn't finished
+// 1 is returned if the player to move wins, -1 if they lose and
+// 0 for a tie. The search stops once the result is outside of
+// the window between alpha and beta
+func solve(s State, alpha, beta int) int {
+	best := -1
+	for _, column := range solverOrder {
+		next, err := s.NextState(column)
+		if err != nil {
+			continue
+		}
+		var value int
+		switch next.Winner {
+		case s.Player:
+			// Nothing is better than winning straight away
+			return 1
+		case Tie:
+			value = 0
+		default:
+			value = -solve(next, -beta, -alpha)
+		}
+		if value > best {
+			best = value
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// StderrBufferSize is the number of lines of an engine's
+	// stderr output that are kept in memory
+	StderrBufferSize = 200
+	// stderrDrainTimeout is the maximum amount of time to wait
+	// for the rest of an engine's stderr output to be read
+	// after its process has terminated
+	stderrDrainTimeout = 1 * time.Second
+)
+
+// StderrLine is a line that an engine has written to stderr
+type StderrLine struct {
+	// Time is the time that the line was read
+	Time time.Time
+	// Text is the line without the trailing newline
+	Text string
+}
+
+// LineBuffer is a ring buffer which holds the
+// most recent lines that have been added to it
+type LineBuffer struct {
+	lock  sync.Mutex
+	lines []StderrLine
+	start int
+	count int
+}
+
+// NewLineBuffer creates an empty LineBuffer which
+// can hold up to size lines
+func NewLineBuffer(size int) *LineBuffer {
+	return &LineBuffer{lines: make([]StderrLine, size)}
+}
+
+// Add adds a line to the buffer, overwriting
+// the oldest line if the buffer is full
+func (b *LineBuffer) Add(line StderrLine) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.lines) == 0 {
+		return
+	}
+	index := (b.start + b.count) % len(b.lines)
+	b.lines[index] = line
+	if b.count < len(b.lines) {
+		b.count++
+	} else {
+		b.start = (b.start + 1) % len(b.lines)
+	}
+}
+
+// Lines returns the lines in the buffer from oldest to newest
+func (b *LineBuffer) Lines() []StderrLine {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	result := make([]StderrLine, b.count)
+	for i := range result {
+		result[i] = b.lines[(b.start+i)%len(b.lines)]
+	}
+	return result
+}
+
+// readStderr reads lines from an engine's stderr into its
+// ring buffer and sends them to the channel set by NotifyStderr.
+// done is closed once there is nothing left to read
+func (e *Engine) readStderr(stderr io.Reader, done chan<- struct{}) {
+	defer close(done)
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := StderrLine{Time: time.Now(), Text: scanner.Text()}
+		e.Stderr.Add(line)
+		e.notifyLock.Lock()
+		if e.stderr != nil {
+			e.stderr <- line
+		}
+		e.notifyLock.Unlock()
+	}
+}
+
+// NotifyStderr sets the channel in which any lines the
+// engine writes to stderr should be sent to
+func (e *Engine) NotifyStderr(channel chan<- StderrLine) {
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	e.stderr = channel
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// QuitGracePeriod is the amount of time an engine is given to
+	// exit by itself after being told to quit before it is killed
+	// unless its definition has a quit timeout
+	QuitGracePeriod = 5 * time.Second
+)
+
+// ExitStatus describes how an engine's process terminated
+type ExitStatus struct {
+	// Time is the time the termination was noticed
+	Time time.Time
+	// Code is the exit code of the process
+	// -1 means that the process was terminated by a signal
+	Code int
+	// Signal is the name of the signal that terminated the
+	// process. It is empty if the process exited by itself
+	Signal string
+	// Killed is true if the process had to be killed
+	// because it didn't quit within its quit timeout
+	Killed bool
+	// Crashed is true if the process terminated without
+	// being told to quit
+	Crashed bool
+	// Limit is the resource limit that the process exceeded
+	// causing it to be killed. It is empty otherwise
+	Limit string
+	// Restarted is true if the engine was restarted
+	// according to its RestartPolicy after crashing
+	Restarted bool
+	// RestartError is the reason a restart failed, if one was attempted
+	RestartError error
+	// Disconnected is true if the engine was remote, in
+	// which case the connection to it was closed
+	Disconnected bool
+}
+
+// String returns a human readable description of the exit status
+func (s ExitStatus) String() string {
+	var result string
+	switch {
+	case s.Limit != "":
+		result = fmt.Sprintf("was killed for exceeding its %s limit", s.Limit)
+	case s.Disconnected:
+		result = "disconnected"
+	case s.Signal != "":
+		result = fmt.Sprintf("terminated by signal %s", s.Signal)
+	default:
+		result = fmt.Sprintf("exited with code %d", s.Code)
+	}
+	if s.Killed && s.Limit == "" {
+		result += " after being killed"
+	}
+	if s.Crashed {
+		result += " (crashed)"
+	}
+	if s.Restarted {
+		result += ", engine has been restarted"
+	} else if s.RestartError != nil {
+		result += ", couldn't restart engine: " + s.RestartError.Error()
+	}
+	return result
+}
+
+// supervise waits for the engine's process to terminate
+// and records how it terminated. Once the exit status has
+// been recorded, the exited channel is closed to notify
+// anything waiting on the engine
+func (e *Engine) supervise() {
+	e.lock.Lock()
+	transport, exited := e.transport, e.exited
+	stderrPipe, stderrDone := e.stderrPipe, e.stderrDone
+	e.lock.Unlock()
+	status := transport.Wait()
+	// Anything written to stderr just before terminating usually
+	// explains why, so it's read before the exit is reported
+	select {
+	case <-stderrDone:
+	case <-time.After(stderrDrainTimeout):
+	}
+	if stderrPipe != nil {
+		stderrPipe.Close()
+	}
+	e.lock.Lock()
+	status.Killed = e.killed
+	status.Crashed = !e.quitting
+	status.Limit = e.breached
+	// The process of a restart which failed is
+	// reported by the restart that started it
+	restarting := e.restarting
+	if status.Crashed && !restarting {
+		e.crashes++
+	}
+	crashes, policy := e.crashes, e.restartPolicy
+	e.exitStatus = status
+	e.ready = false
+	e.thinking = false
+	e.pondering = false
+	e.lock.Unlock()
+	close(exited)
+	if restarting {
+		return
+	}
+	// Bringing the engine back if it's meant to be
+	if status.Crashed && policy.allows(crashes) {
+		status.RestartError = e.restart()
+		status.Restarted = status.RestartError == nil
+	}
+	e.notifyLock.Lock()
+	defer e.notifyLock.Unlock()
+	if e.exits != nil {
+		e.exits <- status
+	}
+}
+
+// Exited returns a channel which is closed once the engine's
+// current process has terminated. LastExit is set before it's closed
+func (e *Engine) Exited() <-chan struct{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.exited
+}
+
+// LastExit describes how the engine's last process terminated
+// It is only valid once the Exited channel has been closed
+func (e *Engine) LastExit() ExitStatus {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.exitStatus
+}
+
+// Crashes gets the number of times the engine's process
+// has terminated without being told to quit
+func (e *Engine) Crashes() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.crashes
+}
+
+// HasExited returns true if the engine's process has terminated
+func (e *Engine) HasExited() bool {
+	select {
+	case <-e.Exited():
+		return true
+	default:
+		return false
+	}
+}
+
+// kill forcefully terminates the engine's process
+// or closes its connection if it's remote
+func (e *Engine) kill() error {
+	if e.HasExited() {
+		return nil
+	}
+	e.lock.Lock()
+	e.killed = true
+	transport := e.transport
+	e.lock.Unlock()
+	return transport.Kill()
+}
+
+// stop kills the engine's process without it being counted as a crash
+// This is used when the engine fails to start properly
+func (e *Engine) stop() {
+	e.lock.Lock()
+	e.quitting = true
+	e.lock.Unlock()
+	e.kill()
+}
+
+// QuitTimeout is the amount of time the engine is given to exit
+// by itself after being told to quit before it is killed
+func (e *Engine) QuitTimeout() time.Duration {
+	if e.Definition.Timeouts.Quit > 0 {
+		return time.Duration(e.Definition.Timeouts.Quit)
+	}
+	return QuitGracePeriod
+}
+
+// waitForExit waits for the engine's process to terminate
+// If it doesn't terminate within the grace period, it is killed
+func (e *Engine) waitForExit(grace time.Duration) error {
+	exited := e.Exited()
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(grace):
+	}
+	if err := e.kill(); err != nil {
+		return err
+	}
+	<-exited
+	return nil
+}
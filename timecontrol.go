@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimeControl is the time each player is given for a whole game
+// rather than for each move. Players are given part of the time
+// on their clock for each move and the time they take is taken
+// off it. A player whose clock runs out loses the game
+type TimeControl struct {
+	// Base is the time each player starts the game with
+	Base time.Duration
+	// Increment is added to a player's clock after each of their moves
+	Increment time.Duration
+}
+
+// ParseTimeControl parses a time control written as the base time
+// and the increment in seconds, e.g. 60+0.5. The increment is optional
+func ParseTimeControl(s string) (TimeControl, error) {
+	parts := strings.SplitN(s, "+", 2)
+	base, err := parseSeconds(parts[0])
+	if err != nil {
+		return TimeControl{}, errors.Wrap(err, "invalid base time")
+	}
+	result := TimeControl{Base: base}
+	if len(parts) == 2 {
+		if result.Increment, err = parseSeconds(parts[1]); err != nil {
+			return TimeControl{}, errors.Wrap(err, "invalid increment")
+		}
+	}
+	if result.Base <= 0 {
+		return TimeControl{}, errors.New("base time must be positive")
+	}
+	if result.Increment < 0 {
+		return TimeControl{}, errors.New("increment can't be negative")
+	}
+	return result, nil
+}
+
+// parseSeconds parses a number of seconds into a duration
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// String writes the time control in the form ParseTimeControl reads
+func (tc TimeControl) String() string {
+	result := strconv.FormatFloat(tc.Base.Seconds(), 'f', -1, 64)
+	if tc.Increment > 0 {
+		result += "+" + strconv.FormatFloat(tc.Increment.Seconds(), 'f', -1, 64)
+	}
+	return result
+}
+
+// MoveTime is how long a player with remaining time on their clock
+// is given to make a move in a position at turn. The time is shared
+// between the moves the player could have left in the game, leaving
+// enough time on the clock for the engine to respond to stop
+func (tc TimeControl) MoveTime(remaining time.Duration, turn int) time.Duration {
+	movesLeft := (43 - turn) / 2
+	if movesLeft < 1 {
+		movesLeft = 1
+	}
+	result := remaining/time.Duration(movesLeft) + tc.Increment
+	if result > remaining/2 {
+		result = remaining / 2
+	}
+	return result
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lateReplyGrace is how long an engine which didn't reply in time
+// is still waited on, so that how long it actually took can be
+// reported with the timeout
+const lateReplyGrace = time.Second
+
+// Names of the timeouts an engine can exceed
+const (
+	TimeoutHandshake = "handshake"
+	TimeoutReadyok   = "readyok"
+	TimeoutBestmove  = "bestmove"
+	TimeoutQuit      = "quit"
+)
+
+// EngineTimeouts are the timeouts of a single engine which
+// override the configured timeouts. Any timeout which is
+// zero uses the configured timeout instead
+type EngineTimeouts struct {
+	// Handshake is how long the engine is given to
+	// perform the CFP handshake
+	Handshake Duration `json:"handshake,omitempty"`
+	// Readyok is how long the engine is given to
+	// respond to isready with readyok
+	Readyok Duration `json:"readyok,omitempty"`
+	// Bestmove is how long the engine is given to
+	// respond to stop with bestmove
+	Bestmove Duration `json:"bestmove,omitempty"`
+	// Quit is how long the engine is given to exit
+	// after being told to quit before it is killed
+	Quit Duration `json:"quit,omitempty"`
+}
+
+// Validate makes sure that none of the timeouts are negative
+func (t EngineTimeouts) Validate() error {
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{TimeoutHandshake, t.Handshake},
+		{TimeoutReadyok, t.Readyok},
+		{TimeoutBestmove, t.Bestmove},
+		{TimeoutQuit, t.Quit},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			return errors.Errorf("%s timeout can't be negative", timeout.name)
+		}
+	}
+	return nil
+}
+
+// CFP returns the timeouts for the CFP protocol, using
+// defaults for any timeout which isn't set
+func (t EngineTimeouts) CFP(defaults CFPTimeouts) CFPTimeouts {
+	result := defaults
+	if t.Handshake > 0 {
+		result.Handshake = time.Duration(t.Handshake)
+	}
+	if t.Readyok > 0 {
+		result.Readyok = time.Duration(t.Readyok)
+	}
+	if t.Bestmove > 0 {
+		result.Bestmove = time.Duration(t.Bestmove)
+	}
+	return result
+}
+
+// TimeoutError is returned when an engine doesn't
+// respond to a command in time
+type TimeoutError struct {
+	// Timeout is the name of the timeout which fired
+	Timeout string
+	// Limit is how long the engine was given
+	Limit time.Duration
+	// Replied is true if the engine replied within lateReplyGrace
+	// of the timeout firing, in which case Took is how long it took
+	// to reply. Otherwise Took is how long it was waited on
+	Replied bool
+	Took    time.Duration
+}
+
+// Error describes which timeout fired and how long the engine took
+func (e TimeoutError) Error() string {
+	if e.Replied {
+		return fmt.Sprintf(
+			"%s timeout of %s fired, engine responded after %s",
+			e.Timeout, e.Limit, e.Took.Round(time.Millisecond),
+		)
+	}
+	return fmt.Sprintf(
+		"%s timeout of %s fired, engine didn't respond within %s",
+		e.Timeout, e.Limit, e.Took.Round(time.Millisecond),
+	)
+}
+
+// cfpRequest is a command sent to an engine
+// which is waiting on a response
+type cfpRequest struct {
+	// sent is the time the command was sent
+	sent time.Time
+	// timeout is the name of the timeout the response is given
+	timeout string
+	limit   time.Duration
+	// expired is true once the timeout has fired
+	expired bool
+	// late is sent how long the engine took if
+	// it replies after the timeout has fired
+	late chan time.Duration
+}
+
+// request records that a command has been sent which the
+// engine should reply to with response
+func (c *CFPProtocol) request(response, timeout string, limit time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.requests[response] = &cfpRequest{
+		sent:    time.Now(),
+		timeout: timeout,
+		limit:   limit,
+		late:    make(chan time.Duration, 1),
+	}
+}
+
+// expire records that the engine didn't reply with response in
+// time. nil is returned if the reply arrived just as the timeout
+// fired, in which case it's waiting to be received
+func (c *CFPProtocol) expire(response string) *cfpRequest {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	r, ok := c.requests[response]
+	if !ok {
+		return nil
+	}
+	r.expired = true
+	return r
+}
+
+// timeout waits lateReplyGrace for an engine which didn't reply
+// in time so that the returned error, which describes the timeout
+// that fired, has how long the engine actually took. nil is returned
+// if the reply arrived just as the timeout fired, in which case
+// it's waiting to be received
+func (c *CFPProtocol) timeout(response string) error {
+	r := c.expire(response)
+	if r == nil {
+		return nil
+	}
+	select {
+	case took := <-r.late:
+		return r.timeoutError(took, true)
+	case <-time.After(lateReplyGrace):
+	case <-c.closed:
+	}
+	return r.timeoutError(time.Since(r.sent), false)
+}
+
+// timeoutError describes the timeout of a request firing
+// replied is whether the engine replied after took
+func (r *cfpRequest) timeoutError(took time.Duration, replied bool) TimeoutError {
+	return TimeoutError{Timeout: r.timeout, Limit: r.limit, Replied: replied, Took: took}
+}
+
+// respond records that the engine replied with response.
+// false is returned if nothing is waiting on the response,
+// either because it wasn't asked for or because the timeout has
+// already fired. In the latter case, how long the engine actually
+// took is sent on late and reported through the info channel
+// so that the timeout can be adjusted
+func (c *CFPProtocol) respond(response string) bool {
+	c.lock.Lock()
+	r, ok := c.requests[response]
+	delete(c.requests, response)
+	c.lock.Unlock()
+	if !ok {
+		return false
+	}
+	if !r.expired {
+		return true
+	}
+	r.late <- time.Since(r.sent)
+	if c.info != nil {
+		c.info <- fmt.Sprintf(
+			"late %s after %s, %s timeout is %s",
+			response, time.Since(r.sent).Round(time.Millisecond),
+			r.timeout, r.limit,
+		)
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RemoteDialTimeout is how long connecting to
+// a remote engine's address can take
+const RemoteDialTimeout = 10 * time.Second
+
+// Transport is how a protocol's lines reach an engine, either
+// through the pipes of a local process or over a network connection
+// A transport is used for a single run of an engine
+type Transport interface {
+	// Pipes returns the engine's input and output
+	// They must be aquired before the transport is started
+	Pipes() (io.WriteCloser, io.ReadCloser, error)
+	// Stderr returns the engine's error output
+	// It is nil if the engine doesn't have one
+	Stderr() (io.ReadCloser, error)
+	// Start starts the engine or connects to it
+	Start() error
+	// Pid is the id of the engine's process
+	// It is 0 if the engine isn't a local process
+	Pid() int
+	// Wait waits for the engine to terminate or disconnect
+	// The exit code and signal of the status are set
+	Wait() ExitStatus
+	// Kill forcefully terminates the engine or its connection
+	Kill() error
+}
+
+// processTransport runs an engine as a child process
+type processTransport struct {
+	cmd *exec.Cmd
+}
+
+// Pipes returns the process's stdin and stdout pipes
+func (t processTransport) Pipes() (io.WriteCloser, io.ReadCloser, error) {
+	stdin, err := t.cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "couldn't aquire stdin pipe")
+	}
+	stdout, err := t.cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "couldn't aquire stdout pipe")
+	}
+	return stdin, stdout, nil
+}
+
+// Stderr returns the process's stderr pipe
+func (t processTransport) Stderr() (io.ReadCloser, error) {
+	return t.cmd.StderrPipe()
+}
+
+// Start starts the process
+func (t processTransport) Start() error {
+	return t.cmd.Start()
+}
+
+// Pid is the id of the started process
+func (t processTransport) Pid() int {
+	return t.cmd.Process.Pid
+}
+
+// Wait waits for the process to exit
+// Note: Process.Wait is used rather than Cmd.Wait as the
+// latter closes the stdout pipe which may still be being
+// read by the communicator
+func (t processTransport) Wait() ExitStatus {
+	state, err := t.cmd.Process.Wait()
+	status := ExitStatus{Time: time.Now(), Code: -1}
+	if err == nil {
+		status.Code = state.ExitCode()
+		if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status.Signal = ws.Signal().String()
+		}
+	}
+	return status
+}
+
+// Kill kills the process if it has been started
+func (t processTransport) Kill() error {
+	if t.cmd.Process == nil {
+		return nil
+	}
+	return t.cmd.Process.Kill()
+}
+
+// tcpTransport talks to an engine listening on a TCP
+// address which speaks the protocol line by line
+// The connection being closed is treated as the engine exiting
+type tcpTransport struct {
+	address string
+	// The ends of the pipes used by the protocol and the ends
+	// which are copied to and from the connection once it's made
+	stdinReader  *io.PipeReader
+	stdinWriter  *io.PipeWriter
+	stdoutReader *io.PipeReader
+	stdoutWriter *io.PipeWriter
+	lock         sync.Mutex
+	conn         net.Conn
+	// disconnected is closed once the connection has been closed
+	disconnected chan struct{}
+}
+
+// newTCPTransport creates a transport which
+// connects to an engine at an address when started
+func newTCPTransport(address string) *tcpTransport {
+	t := tcpTransport{address: address, disconnected: make(chan struct{})}
+	t.stdinReader, t.stdinWriter = io.Pipe()
+	t.stdoutReader, t.stdoutWriter = io.Pipe()
+	return &t
+}
+
+// Pipes returns the pipes which are joined to the connection
+func (t *tcpTransport) Pipes() (io.WriteCloser, io.ReadCloser, error) {
+	return t.stdinWriter, t.stdoutReader, nil
+}
+
+// Stderr returns nil as remote engines don't have stderr
+func (t *tcpTransport) Stderr() (io.ReadCloser, error) {
+	return nil, nil
+}
+
+// Start connects to the engine and starts copying lines between
+// the pipes and the connection. Closing the input pipe closes the
+// sending side of the connection so that the engine can finish
+// after quitting, while closing the output pipe disconnects
+func (t *tcpTransport) Start() error {
+	conn, err := net.DialTimeout("tcp", t.address, RemoteDialTimeout)
+	if err != nil {
+		return errors.Wrap(err, "couldn't connect to engine")
+	}
+	t.lock.Lock()
+	t.conn = conn
+	t.lock.Unlock()
+	go func() {
+		_, err := io.Copy(conn, t.stdinReader)
+		if err != nil {
+			// Writes would otherwise block as nothing is reading
+			t.stdinReader.CloseWithError(err)
+			return
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}()
+	go func() {
+		_, err := io.Copy(t.stdoutWriter, conn)
+		t.stdoutWriter.CloseWithError(err)
+		conn.Close()
+		t.stdinReader.CloseWithError(io.ErrClosedPipe)
+		close(t.disconnected)
+	}()
+	return nil
+}
+
+// Pid is 0 as the engine isn't a local process
+func (t *tcpTransport) Pid() int {
+	return 0
+}
+
+// Wait waits for the connection to be closed
+func (t *tcpTransport) Wait() ExitStatus {
+	<-t.disconnected
+	return ExitStatus{Time: time.Now(), Disconnected: true}
+}
+
+// Kill closes the connection if it has been made
+func (t *tcpTransport) Kill() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveLoopback accepts a connection on l and speaks CFP over it
+// as an engine which always plays column 3. The connection is
+// sent on conns so that it can be closed by the test
+func serveLoopback(l net.Listener, conns chan<- net.Conn) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	conns <- conn
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		command := strings.Fields(scanner.Text())
+		if len(command) == 0 {
+			continue
+		}
+		switch command[0] {
+		case "cfp":
+			fmt.Fprint(conn, "id name Loopback\nid author Test\ncfpok\n")
+		case "isready":
+			fmt.Fprint(conn, "readyok\n")
+		case "stop":
+			fmt.Fprint(conn, "info depth 1 score 0 pv 3\nbestmove 3\n")
+		case "quit":
+			conn.Close()
+			return
+		}
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	conns := make(chan net.Conn, 1)
+	go serveLoopback(l, conns)
+
+	e, err := NewEngine(EngineDefinition{Address: l.Addr().String()}, NewCFP(DefaultCFPTimeouts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Load(); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	if e.Name != "Loopback" || e.Author != "Test" {
+		t.Errorf("got engine %q by %q, want %q by %q", e.Name, e.Author, "Loopback", "Test")
+	}
+
+	if err := e.NewGame(); err != nil {
+		t.Fatalf("new game: %v", err)
+	}
+	if err := e.Position(NewState()); err != nil {
+		t.Fatalf("position: %v", err)
+	}
+	if err := e.Go(time.Second, SearchLimits{}); err != nil {
+		t.Fatalf("go: %v", err)
+	}
+	move, err := e.Stop()
+	if err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if move != 3 {
+		t.Errorf("got move %d, want 3", move)
+	}
+
+	// The engine terminates when its connection is closed
+	(<-conns).Close()
+	select {
+	case <-e.Exited():
+	case <-time.After(5 * time.Second):
+		t.Fatal("engine didn't exit after its connection was closed")
+	}
+	if status := e.LastExit(); !status.Disconnected || !status.Crashed {
+		t.Errorf("got exit status %+v, want a disconnection", status)
+	}
+}
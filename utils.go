@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -60,3 +61,34 @@ func FilesAt(path string) ([]string, error) {
 	// Return the result
 	return result, nil
 }
+
+// Duration is a time.Duration which is written to and read from
+// JSON as a string such as "1m30s". A number is also accepted
+// when reading and is interpreted as a number of seconds
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads the duration from a string or a number of seconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.Wrap(err, "invalid duration")
+		}
+		*d = Duration(parsed)
+	default:
+		return errors.New("invalid duration")
+	}
+	return nil
+}
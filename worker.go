@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultWorkerAddress is the address a worker listens on
+// if it isn't given one
+const DefaultWorkerAddress = ":4700"
+
+// WorkerJob is a game which a worker is asked to play. Jobs are
+// sent to workers as a line of JSON and the worker replies with
+// a line of JSON containing a WorkerReply once the game is over
+type WorkerJob struct {
+	// Game is the index of the game within the match
+	Game int `json:"game"`
+	// Engine1 and Engine2 are the engines playing as Player1 and
+	// Player2, RELATIVE to the worker's engine directory
+	Engine1 string `json:"engine1"`
+	Engine2 string `json:"engine2"`
+	// MoveTime is the time given for each move
+	// when there isn't a time control
+	MoveTime Duration `json:"movetime"`
+	// TimeControl is written as base+increment in seconds
+	// It is empty if MoveTime is used instead
+	TimeControl string `json:"timecontrol,omitempty"`
+	// Opening is the position the game starts from in CFP
+	Opening      string        `json:"opening"`
+	Adjudication *Adjudication `json:"adjudication,omitempty"`
+	Ponder       bool          `json:"ponder,omitempty"`
+}
+
+// WorkerReply is a worker's response to a job
+type WorkerReply struct {
+	Game int `json:"game"`
+	// Result is the finished game, or nil if the game
+	// couldn't be played, in which case Error says why
+	Result *GameResult `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// runWorker runs a worker which plays the games it's sent over TCP
+// by coordinators, e.g. konnect4 match -workers host:port
+// It runs until it's killed
+func runWorker(args []string) int {
+	flags := flag.NewFlagSet(ApplicationName+" worker", flag.ContinueOnError)
+	address := flags.String("address", DefaultWorkerAddress, "address to listen for coordinators on")
+	configuration := addConfigFlags(flags)
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	config, err := configuration.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config.UseDirectories()
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "couldn't listen for coordinators"))
+		return 1
+	}
+	fmt.Printf("Worker listening on %s\n", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrap(err, "couldn't accept coordinator"))
+			return 1
+		}
+		go serveCoordinator(conn, config.CFPTimeouts())
+	}
+}
+
+// serveCoordinator plays each of the jobs a coordinator sends until
+// it disconnects. Engines are kept loaded between jobs so that they
+// only need to be started once for each connection
+func serveCoordinator(conn net.Conn, timeouts CFPTimeouts) {
+	defer conn.Close()
+	fmt.Printf("Coordinator %s connected\n", conn.RemoteAddr())
+	w := worker{timeouts: timeouts, engines: make(map[string][]*Engine)}
+	defer w.quit()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var job WorkerJob
+		if err := json.Unmarshal(scanner.Bytes(), &job); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrap(err, "couldn't parse job"))
+			break
+		}
+		fmt.Printf("Playing game %d: %s vs %s\n", job.Game+1, job.Engine1, job.Engine2)
+		reply := WorkerReply{Game: job.Game}
+		if result, err := w.play(job); err != nil {
+			fmt.Fprintf(os.Stderr, "Game %d failed: %s\n", job.Game+1, err)
+			reply.Error = err.Error()
+		} else {
+			reply.Result = &result
+		}
+		if err := encoder.Encode(reply); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrap(err, "couldn't send result"))
+			break
+		}
+	}
+	fmt.Printf("Coordinator %s disconnected\n", conn.RemoteAddr())
+}
+
+// worker is the state of a worker's connection to a coordinator
+type worker struct {
+	timeouts CFPTimeouts
+	// engines are the loaded engines for each path
+	engines map[string][]*Engine
+}
+
+// play plays the game of a job
+func (w *worker) play(job WorkerJob) (GameResult, error) {
+	opening, err := ParseOpening(job.Opening)
+	if err != nil {
+		return GameResult{}, errors.Wrap(err, "invalid opening")
+	}
+	player1, err := w.engine(job.Engine1, nil)
+	if err != nil {
+		return GameResult{}, errors.Wrap(err, "couldn't load engine1")
+	}
+	player2, err := w.engine(job.Engine2, player1)
+	if err != nil {
+		return GameResult{}, errors.Wrap(err, "couldn't load engine2")
+	}
+	player1.SetPonder(job.Ponder)
+	player2.SetPonder(job.Ponder)
+	match, err := NewMatch(player1, player2, 1, time.Duration(job.MoveTime))
+	if err != nil {
+		return GameResult{}, err
+	}
+	if job.TimeControl != "" {
+		tc, err := ParseTimeControl(job.TimeControl)
+		if err != nil {
+			return GameResult{}, errors.Wrap(err, "invalid time control")
+		}
+		match.TimeControl = &tc
+	}
+	if job.Adjudication != nil {
+		if err := job.Adjudication.Validate(); err != nil {
+			return GameResult{}, errors.Wrap(err, "invalid adjudication")
+		}
+		match.Adjudication = job.Adjudication
+	}
+	return match.playGame(player1, player2, opening)
+}
+
+// engine gets a loaded engine for path which isn't other, loading
+// a new one if there isn't one. Engines which have exited are
+// replaced so that a crash only affects the game it happened in
+func (w *worker) engine(path string, other *Engine) (*Engine, error) {
+	engines := w.engines[path][:0]
+	for _, e := range w.engines[path] {
+		if !e.HasExited() {
+			engines = append(engines, e)
+		}
+	}
+	w.engines[path] = engines
+	for _, e := range engines {
+		if e != other {
+			return e, nil
+		}
+	}
+	e, err := loadMatchEngine(path, w.timeouts)
+	if err != nil {
+		return nil, err
+	}
+	w.engines[path] = append(w.engines[path], e)
+	return e, nil
+}
+
+// quit quits all of the loaded engines
+func (w *worker) quit() {
+	for _, engines := range w.engines {
+		for _, e := range engines {
+			e.Quit()
+		}
+	}
+}